@@ -0,0 +1,264 @@
+package neo4j
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	krbclient "github.com/jcmturner/gokrb5/v8/client"
+	krbconfig "github.com/jcmturner/gokrb5/v8/config"
+	"github.com/jcmturner/gokrb5/v8/credentials"
+	"github.com/jcmturner/gokrb5/v8/gssapi"
+	"github.com/jcmturner/gokrb5/v8/keytab"
+	"github.com/jcmturner/gokrb5/v8/spnego"
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j/auth"
+)
+
+const (
+	authMethodBasic    = "basic"
+	authMethodBearer   = "bearer"
+	authMethodKerberos = "kerberos"
+)
+
+// defaultKerberosConfig is the krb5.conf read unless configured otherwise.
+const defaultKerberosConfig = "/etc/krb5.conf"
+
+// authTokenManager returns the credentials of the admin connection for the
+// configured auth_method. Tokens read from files are re-read, and kerberos
+// tickets obtained anew, whenever they expire or neo4j rejects them.
+func (c *neo4jConnectionProducer) authTokenManager() auth.TokenManager {
+	switch c.AuthMethod {
+	case authMethodBearer:
+		if c.TokenFile != "" {
+			return auth.BearerTokenManager(c.readBearerToken)
+		}
+		return neo4j.BearerAuth(c.Token)
+	case authMethodKerberos:
+		return auth.BearerTokenManager(c.readKerberosTicket)
+	default:
+		return neo4j.BasicAuth(c.Username, c.Password, "")
+	}
+}
+
+func (c *neo4jConnectionProducer) readBearerToken(context.Context) (neo4j.AuthToken, *time.Time, error) {
+	token, err := readCredentialFile("token_file", c.TokenFile)
+	if err != nil {
+		return neo4j.AuthToken{}, nil, err
+	}
+	return neo4j.BearerAuth(token), jwtExpiration(token), nil
+}
+
+// readKerberosTicket obtains a service ticket for neo4j from the KDC, with the
+// TGT of the credential cache or by logging in with the keytab, and returns it
+// as the GSS-API token neo4j expects. The files are read anew each time, so
+// that a credential cache renewed by e.g. kinit or k5start is picked up.
+func (c *neo4jConnectionProducer) readKerberosTicket(context.Context) (neo4j.AuthToken, *time.Time, error) {
+	krbClient, err := c.kerberosClient()
+	if err != nil {
+		return neo4j.AuthToken{}, nil, err
+	}
+	defer krbClient.Destroy()
+
+	servicePrincipal := c.kerberosServicePrincipal()
+	ticket, sessionKey, err := krbClient.GetServiceTicket(servicePrincipal)
+	if err != nil {
+		return neo4j.AuthToken{}, nil, fmt.Errorf("failed to obtain a kerberos service ticket for %q: %w", servicePrincipal, err)
+	}
+
+	token, err := spnego.NewKRB5TokenAPREQ(krbClient, ticket, sessionKey, []int{gssapi.ContextFlagInteg, gssapi.ContextFlagConf}, nil)
+	if err != nil {
+		return neo4j.AuthToken{}, nil, fmt.Errorf("failed to create the kerberos token: %w", err)
+	}
+	data, err := token.Marshal()
+	if err != nil {
+		return neo4j.AuthToken{}, nil, fmt.Errorf("failed to create the kerberos token: %w", err)
+	}
+	return neo4j.KerberosAuth(base64.StdEncoding.EncodeToString(data)), nil, nil
+}
+
+// kerberosClient returns a kerberos client holding a TGT, either from the
+// kerberos_ccache or logged in with the kerberos_keytab.
+func (c *neo4jConnectionProducer) kerberosClient() (*krbclient.Client, error) {
+	krb5conf, err := krbconfig.Load(c.KerberosConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read kerberos_config: %w", err)
+	}
+
+	if c.KerberosCCache != "" {
+		ccache, err := credentials.LoadCCache(c.KerberosCCache)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read kerberos_ccache: %w", err)
+		}
+		krbClient, err := krbclient.NewFromCCache(ccache, krb5conf, krbclient.DisablePAFXFAST(true))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read kerberos_ccache: %w", err)
+		}
+		return krbClient, nil
+	}
+
+	kt, err := keytab.Load(c.KerberosKeytab)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read kerberos_keytab: %w", err)
+	}
+	username, realm := splitKerberosPrincipal(c.kerberosPrincipal(), krb5conf.LibDefaults.DefaultRealm)
+	krbClient := krbclient.NewWithKeytab(username, realm, kt, krb5conf, krbclient.DisablePAFXFAST(true))
+	if err := krbClient.Login(); err != nil {
+		return nil, fmt.Errorf("failed to log in to kerberos as %q: %w", c.kerberosPrincipal(), err)
+	}
+	return krbClient, nil
+}
+
+// kerberosPrincipal returns the principal to log in as with the keytab, which
+// defaults to the username.
+func (c *neo4jConnectionProducer) kerberosPrincipal() string {
+	if c.KerberosPrincipal != "" {
+		return c.KerberosPrincipal
+	}
+	return c.Username
+}
+
+// kerberosServicePrincipal returns the principal of the neo4j service, which
+// defaults to neo4j/<host of the connection_url>.
+func (c *neo4jConnectionProducer) kerberosServicePrincipal() string {
+	if c.KerberosServicePrincipal != "" {
+		return c.KerberosServicePrincipal
+	}
+	u, err := url.Parse(c.ConnectionURL)
+	if err != nil || u.Hostname() == "" {
+		return ""
+	}
+	return "neo4j/" + u.Hostname()
+}
+
+// splitKerberosPrincipal splits a principal of the form user@REALM into the
+// user and the realm, which defaults to the given realm.
+func splitKerberosPrincipal(principal, defaultRealm string) (string, string) {
+	if i := strings.LastIndex(principal, "@"); i >= 0 {
+		return principal[:i], principal[i+1:]
+	}
+	return principal, defaultRealm
+}
+
+func readCredentialFile(field, path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s: %w", field, err)
+	}
+
+	credential := strings.TrimSpace(string(data))
+	if credential == "" {
+		return "", fmt.Errorf("%s %q is empty", field, path)
+	}
+	return credential, nil
+}
+
+// jwtExpiration returns the expiration of the token if it is a JWT with an
+// exp claim, so that the token file is re-read before the token expires.
+func jwtExpiration(token string) *time.Time {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil
+	}
+
+	var claims struct {
+		Exp int64 `json:"exp"`
+	}
+	if err := json.Unmarshal(payload, &claims); err != nil || claims.Exp == 0 {
+		return nil
+	}
+
+	expiration := time.Unix(claims.Exp, 0)
+	return &expiration
+}
+
+// loadAuthConfig validates the settings required by the auth_method and the
+// auth_failure_cooldown. A static bearer token can also be given as the
+// password, which is how it is stored by Vault, see responseConfig.
+func (c *neo4jConnectionProducer) loadAuthConfig() error {
+	if c.AuthFailureCooldown < 0 {
+		return fmt.Errorf("auth_failure_cooldown must be >= 0")
+	}
+	if c.AuthFailureCooldown == 0 {
+		c.AuthFailureCooldown = defaultAuthFailureCooldown
+	}
+
+	switch c.AuthMethod {
+	case "":
+		c.AuthMethod = authMethodBasic
+	case authMethodBasic:
+	case authMethodBearer:
+		if c.Token != "" && c.TokenFile != "" {
+			return fmt.Errorf("token and token_file are mutually exclusive")
+		}
+		if c.Token == "" && c.TokenFile == "" {
+			c.Token = c.Password
+		}
+		if c.Token == "" && c.TokenFile == "" {
+			return fmt.Errorf("token or token_file is required for auth_method %q", authMethodBearer)
+		}
+	case authMethodKerberos:
+		if c.KerberosKeytab != "" && c.KerberosCCache != "" {
+			return fmt.Errorf("kerberos_keytab and kerberos_ccache are mutually exclusive")
+		}
+		if c.KerberosKeytab == "" && c.KerberosCCache == "" {
+			return fmt.Errorf("kerberos_keytab or kerberos_ccache is required for auth_method %q", authMethodKerberos)
+		}
+		if c.KerberosKeytab != "" && c.kerberosPrincipal() == "" {
+			return fmt.Errorf("kerberos_principal or username is required with kerberos_keytab")
+		}
+		if c.kerberosServicePrincipal() == "" {
+			return fmt.Errorf("kerberos_service_principal is required if the connection_url has no host")
+		}
+		if c.KerberosConfig == "" {
+			c.KerberosConfig = defaultKerberosConfig
+		}
+	default:
+		return fmt.Errorf("auth_method must be %q, %q or %q", authMethodBasic, authMethodBearer, authMethodKerberos)
+	}
+	return nil
+}
+
+// secretConfigKeys are the settings holding secrets, which are kept out of the
+// RawConfig.
+var secretConfigKeys = []string{"password", "token", "tls_certificate_key"}
+
+// withoutSecrets returns a copy of the config without the secret settings.
+func withoutSecrets(config map[string]interface{}) map[string]interface{} {
+	result := make(map[string]interface{}, len(config))
+	for key, value := range config {
+		result[key] = value
+	}
+	for _, key := range secretConfigKeys {
+		delete(result, key)
+	}
+	return result
+}
+
+// responseConfig returns the config to be stored by Vault. Vault never returns
+// the password when reading the connection, so a static bearer token is moved
+// to the password.
+func responseConfig(config map[string]interface{}) map[string]interface{} {
+	token, ok := config["token"]
+	if !ok {
+		return config
+	}
+
+	result := make(map[string]interface{}, len(config))
+	for key, value := range config {
+		result[key] = value
+	}
+	delete(result, "token")
+	result["password"] = token
+	return result
+}
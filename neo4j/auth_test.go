@@ -0,0 +1,248 @@
+package neo4j
+
+import (
+	"context"
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/jcmturner/gokrb5/v8/iana/etypeID"
+	"github.com/jcmturner/gokrb5/v8/keytab"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadAuthConfig(t *testing.T) {
+	type testCase struct {
+		config map[string]interface{}
+
+		expectMethod string
+		expectToken  string
+		expectErr    string
+	}
+
+	tests := map[string]testCase{
+		"default": {
+			config: map[string]interface{}{
+				"username": "neo4j",
+				"password": "secret",
+			},
+			expectMethod: authMethodBasic,
+		},
+		"bearer token": {
+			config: map[string]interface{}{
+				"auth_method": "bearer",
+				"token":       "sso-token",
+			},
+			expectMethod: authMethodBearer,
+			expectToken:  "sso-token",
+		},
+		"bearer token stored as password": {
+			config: map[string]interface{}{
+				"auth_method": "bearer",
+				"password":    "sso-token",
+			},
+			expectMethod: authMethodBearer,
+			expectToken:  "sso-token",
+		},
+		"bearer token file": {
+			config: map[string]interface{}{
+				"auth_method": "bearer",
+				"token_file":  "/var/run/secrets/neo4j/token",
+			},
+			expectMethod: authMethodBearer,
+		},
+		"bearer without token": {
+			config: map[string]interface{}{
+				"auth_method": "bearer",
+			},
+			expectErr: `token or token_file is required for auth_method "bearer"`,
+		},
+		"bearer with token and token file": {
+			config: map[string]interface{}{
+				"auth_method": "bearer",
+				"token":       "sso-token",
+				"token_file":  "/var/run/secrets/neo4j/token",
+			},
+			expectErr: "token and token_file are mutually exclusive",
+		},
+		"kerberos keytab": {
+			config: map[string]interface{}{
+				"auth_method":     "kerberos",
+				"username":        "vault@EXAMPLE.COM",
+				"kerberos_keytab": "/etc/vault/neo4j.keytab",
+			},
+			expectMethod: authMethodKerberos,
+		},
+		"kerberos ccache": {
+			config: map[string]interface{}{
+				"auth_method":     "kerberos",
+				"kerberos_ccache": "/tmp/krb5cc_vault",
+			},
+			expectMethod: authMethodKerberos,
+		},
+		"kerberos without keytab or ccache": {
+			config: map[string]interface{}{
+				"auth_method": "kerberos",
+			},
+			expectErr: `kerberos_keytab or kerberos_ccache is required for auth_method "kerberos"`,
+		},
+		"kerberos with keytab and ccache": {
+			config: map[string]interface{}{
+				"auth_method":     "kerberos",
+				"username":        "vault@EXAMPLE.COM",
+				"kerberos_keytab": "/etc/vault/neo4j.keytab",
+				"kerberos_ccache": "/tmp/krb5cc_vault",
+			},
+			expectErr: "kerberos_keytab and kerberos_ccache are mutually exclusive",
+		},
+		"kerberos keytab without principal": {
+			config: map[string]interface{}{
+				"auth_method":     "kerberos",
+				"kerberos_keytab": "/etc/vault/neo4j.keytab",
+			},
+			expectErr: "kerberos_principal or username is required with kerberos_keytab",
+		},
+		"unsupported method": {
+			config: map[string]interface{}{
+				"auth_method": "ldap",
+			},
+			expectErr: `auth_method must be "basic", "bearer" or "kerberos"`,
+		},
+		"negative auth failure cooldown": {
+			config: map[string]interface{}{
+				"auth_failure_cooldown": "-1m",
+			},
+			expectErr: "auth_failure_cooldown must be >= 0",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			c := new()
+			test.config["connection_url"] = "neo4j://localhost:7687"
+			err := c.loadConfig(test.config)
+			if test.expectErr != "" {
+				require.EqualError(t, err, test.expectErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, test.expectMethod, c.AuthMethod)
+			require.Equal(t, test.expectToken, c.Token)
+			require.Equal(t, defaultAuthFailureCooldown, c.AuthFailureCooldown)
+		})
+	}
+}
+
+func TestReadBearerToken(t *testing.T) {
+	exp := time.Now().Add(time.Hour).Truncate(time.Second)
+	claims := base64.RawURLEncoding.EncodeToString([]byte(`{"sub":"vault","exp":` + strconv.FormatInt(exp.Unix(), 10) + `}`))
+	token := "header." + claims + ".signature"
+
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	require.NoError(t, os.WriteFile(tokenFile, []byte(token+"\n"), 0o600))
+
+	c := new()
+	c.TokenFile = tokenFile
+
+	authToken, expiration, err := c.readBearerToken(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, token, authToken.Tokens["credentials"])
+	require.NotNil(t, expiration)
+	require.True(t, exp.Equal(*expiration))
+
+	require.NoError(t, os.WriteFile(tokenFile, []byte("opaque-token"), 0o600))
+	authToken, expiration, err = c.readBearerToken(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, "opaque-token", authToken.Tokens["credentials"])
+	require.Nil(t, expiration)
+
+	require.NoError(t, os.WriteFile(tokenFile, nil, 0o600))
+	_, _, err = c.readBearerToken(context.Background())
+	require.ErrorContains(t, err, "is empty")
+}
+
+func TestKerberosPrincipals(t *testing.T) {
+	c := new()
+	c.ConnectionURL = "neo4j://neo4j.example.com:7687"
+	c.Username = "vault@EXAMPLE.COM"
+
+	require.Equal(t, "vault@EXAMPLE.COM", c.kerberosPrincipal())
+	require.Equal(t, "neo4j/neo4j.example.com", c.kerberosServicePrincipal())
+
+	c.KerberosPrincipal = "neo4j-admin@EXAMPLE.COM"
+	c.KerberosServicePrincipal = "neo4j/cluster.example.com"
+	require.Equal(t, "neo4j-admin@EXAMPLE.COM", c.kerberosPrincipal())
+	require.Equal(t, "neo4j/cluster.example.com", c.kerberosServicePrincipal())
+
+	username, realm := splitKerberosPrincipal("vault@EXAMPLE.COM", "DEFAULT.COM")
+	require.Equal(t, "vault", username)
+	require.Equal(t, "EXAMPLE.COM", realm)
+
+	username, realm = splitKerberosPrincipal("vault", "DEFAULT.COM")
+	require.Equal(t, "vault", username)
+	require.Equal(t, "DEFAULT.COM", realm)
+}
+
+func TestReadKerberosTicket(t *testing.T) {
+	dir := t.TempDir()
+
+	// No KDC listens on the discard port, so logging in fails.
+	krb5conf := filepath.Join(dir, "krb5.conf")
+	require.NoError(t, os.WriteFile(krb5conf, []byte(`[libdefaults]
+  default_realm = EXAMPLE.COM
+  dns_lookup_kdc = false
+  udp_preference_limit = 1
+
+[realms]
+  EXAMPLE.COM = {
+    kdc = 127.0.0.1:9
+  }
+`), 0o600))
+
+	kt := keytab.New()
+	require.NoError(t, kt.AddEntry("vault", "EXAMPLE.COM", "secret", time.Now(), 1, etypeID.AES256_CTS_HMAC_SHA1_96))
+	ktData, err := kt.Marshal()
+	require.NoError(t, err)
+	ktFile := filepath.Join(dir, "vault.keytab")
+	require.NoError(t, os.WriteFile(ktFile, ktData, 0o600))
+
+	c := new()
+	c.ConnectionURL = "neo4j://neo4j.example.com:7687"
+	c.KerberosConfig = krb5conf
+	c.KerberosKeytab = ktFile
+	c.KerberosPrincipal = "vault"
+
+	_, _, err = c.readKerberosTicket(context.Background())
+	require.ErrorContains(t, err, `failed to log in to kerberos as "vault"`)
+
+	c.KerberosKeytab = filepath.Join(dir, "missing.keytab")
+	_, _, err = c.readKerberosTicket(context.Background())
+	require.ErrorContains(t, err, "failed to read kerberos_keytab")
+
+	c.KerberosKeytab = ""
+	c.KerberosCCache = krb5conf
+	_, _, err = c.readKerberosTicket(context.Background())
+	require.ErrorContains(t, err, "failed to read kerberos_ccache")
+}
+
+func TestResponseConfig(t *testing.T) {
+	config := map[string]interface{}{
+		"connection_url": "neo4j://localhost:7687",
+		"auth_method":    "bearer",
+		"token":          "sso-token",
+	}
+
+	require.Equal(t, map[string]interface{}{
+		"connection_url": "neo4j://localhost:7687",
+		"auth_method":    "bearer",
+		"password":       "sso-token",
+	}, responseConfig(config))
+
+	require.Equal(t, map[string]interface{}{
+		"connection_url": "neo4j://localhost:7687",
+		"auth_method":    "bearer",
+	}, withoutSecrets(config))
+}
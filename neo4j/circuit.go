@@ -0,0 +1,147 @@
+package neo4j
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+)
+
+// defaultAuthFailureCooldown is how long the plugin stops connecting after
+// neo4j rejected the admin credentials, unless configured otherwise.
+const defaultAuthFailureCooldown = 5 * time.Minute
+
+// Error codes of servers rejecting the admin credentials.
+const (
+	unauthorizedCode            = "Neo.ClientError.Security.Unauthorized"
+	authenticationRateLimitCode = "Neo.ClientError.Security.AuthenticationRateLimit"
+)
+
+// rereadAuthFailures is the number of consecutive rejections of credentials
+// the driver re-reads, i.e. a token_file or kerberos tickets, that open the
+// auth circuit. The first rejection only makes the driver re-read them.
+const rereadAuthFailures = 2
+
+// errAdminCredentialsRejected is returned while the auth circuit is open.
+var errAdminCredentialsRejected = errors.New("admin credentials rejected")
+
+// authCircuit stops all connection attempts for the auth_failure_cooldown
+// once neo4j rejected the admin credentials. neo4j locks out users after
+// dbms.security.auth_max_failed_attempts, so retrying with credentials that
+// were e.g. changed out-of-band would soon lock out the admin user for
+// everyone else too.
+type authCircuit struct {
+	openUntil time.Time
+	cause     error
+	failures  int
+
+	sync.Mutex
+}
+
+// isAuthError reports whether neo4j rejected the credentials of a connection.
+func isAuthError(err error) bool {
+	var executionLimit *neo4j.TransactionExecutionLimit
+	if errors.As(err, &executionLimit) {
+		if len(executionLimit.Errors) == 0 {
+			return false
+		}
+		return isAuthError(executionLimit.Errors[len(executionLimit.Errors)-1])
+	}
+
+	var neo4jErr *neo4j.Neo4jError
+	if errors.As(err, &neo4jErr) {
+		return neo4jErr.Code == unauthorizedCode || neo4jErr.Code == authenticationRateLimitCode
+	}
+	return false
+}
+
+// checkAuthCircuit returns an error without contacting neo4j while the auth
+// circuit is open.
+func (c *neo4jConnectionProducer) checkAuthCircuit() error {
+	c.authCircuit.Lock()
+	defer c.authCircuit.Unlock()
+
+	if time.Now().Before(c.authCircuit.openUntil) {
+		return fmt.Errorf("%w, not connecting until %s: %v", errAdminCredentialsRejected, c.authCircuit.openUntil.Format(time.RFC3339), c.authCircuit.cause)
+	}
+	return nil
+}
+
+// observeAuthError opens the auth circuit if err shows that neo4j rejected
+// the admin credentials. Static credentials open it at once, credentials the
+// driver re-reads only if they are rejected again after the re-read. Other
+// errors are returned as they are.
+func (c *neo4jConnectionProducer) observeAuthError(err error) error {
+	if err == nil {
+		c.authCircuit.Lock()
+		c.authCircuit.failures = 0
+		c.authCircuit.Unlock()
+		return nil
+	}
+	if !isAuthError(err) {
+		return err
+	}
+
+	threshold := 1
+	if c.rereadsCredentials() {
+		threshold = rereadAuthFailures
+	}
+
+	c.authCircuit.Lock()
+	c.authCircuit.failures++
+	if c.authCircuit.failures < threshold {
+		c.authCircuit.Unlock()
+		c.logger.Warn("admin credentials rejected by neo4j, re-reading them", "username", c.Username, "auth_method", c.AuthMethod, "error", c.sanitizeError(err, nil))
+		return err
+	}
+	openUntil := time.Now().Add(c.AuthFailureCooldown)
+	c.authCircuit.openUntil = openUntil
+	c.authCircuit.cause = err
+	c.authCircuit.failures = 0
+	c.authCircuit.Unlock()
+
+	c.logger.Error("ADMIN CREDENTIALS REJECTED by neo4j, all operations fail until the cool-down expires or the connection is reconfigured, check the credentials of the admin user",
+		"username", c.Username, "auth_method", c.AuthMethod, "until", openUntil, "error", c.sanitizeError(err, nil))
+	return fmt.Errorf("%w: %w", errAdminCredentialsRejected, err)
+}
+
+// rereadsCredentials reports whether the driver re-reads the admin credentials
+// after neo4j rejected them, see authTokenManager.
+func (c *neo4jConnectionProducer) rereadsCredentials() bool {
+	switch c.AuthMethod {
+	case authMethodBearer:
+		return c.TokenFile != ""
+	case authMethodKerberos:
+		return true
+	}
+	return false
+}
+
+// resetAuthCircuit closes the auth circuit, e.g. after the credentials were
+// reconfigured.
+func (c *neo4jConnectionProducer) resetAuthCircuit() {
+	c.authCircuit.Lock()
+	defer c.authCircuit.Unlock()
+
+	c.authCircuit.openUntil = time.Time{}
+	c.authCircuit.cause = nil
+	c.authCircuit.failures = 0
+}
+
+// withAuthCircuit runs an operation unless the auth circuit is open, and
+// opens it if the operation fails because of the admin credentials. If the
+// driver re-reads the rejected credentials, the operation is run once more
+// with them.
+func (c *neo4jConnectionProducer) withAuthCircuit(ctx context.Context, operation func(context.Context) error) error {
+	if err := c.checkAuthCircuit(); err != nil {
+		return err
+	}
+	err := c.observeAuthError(operation(ctx))
+	if isAuthError(err) && !errors.Is(err, errAdminCredentialsRejected) {
+		err = c.observeAuthError(operation(ctx))
+	}
+	return err
+}
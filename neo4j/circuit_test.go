@@ -0,0 +1,142 @@
+package neo4j
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsAuthError(t *testing.T) {
+	tests := map[string]struct {
+		err      error
+		expected bool
+	}{
+		"nil": {
+			err: nil,
+		},
+		"unauthorized": {
+			err:      &neo4j.Neo4jError{Code: "Neo.ClientError.Security.Unauthorized"},
+			expected: true,
+		},
+		"rate limit": {
+			err:      fmt.Errorf("failed: %w", &neo4j.Neo4jError{Code: "Neo.ClientError.Security.AuthenticationRateLimit"}),
+			expected: true,
+		},
+		"transaction execution limit": {
+			err: &neo4j.TransactionExecutionLimit{
+				Cause:  "timeout",
+				Errors: []error{&neo4j.Neo4jError{Code: "Neo.ClientError.Security.Unauthorized"}},
+			},
+			expected: true,
+		},
+		"forbidden": {
+			err: &neo4j.Neo4jError{Code: "Neo.ClientError.Security.Forbidden"},
+		},
+		"other error": {
+			err: errors.New("boom"),
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			require.Equal(t, test.expected, isAuthError(test.err))
+		})
+	}
+}
+
+func TestAuthCircuit(t *testing.T) {
+	unauthorizedErr := &neo4j.Neo4jError{Code: "Neo.ClientError.Security.Unauthorized", Msg: "The client is unauthorized due to authentication failure."}
+
+	c := new()
+	c.Initialized = true
+	c.AuthFailureCooldown = time.Minute
+
+	attempts := 0
+	failing := func(context.Context) error {
+		attempts++
+		return unauthorizedErr
+	}
+
+	err := c.withAuthCircuit(context.Background(), failing)
+	require.ErrorIs(t, err, errAdminCredentialsRejected)
+	require.ErrorIs(t, err, unauthorizedErr)
+	require.Equal(t, 1, attempts)
+
+	// The circuit is open, operations fail without contacting neo4j.
+	err = c.withAuthCircuit(context.Background(), failing)
+	require.ErrorIs(t, err, errAdminCredentialsRejected)
+	require.Contains(t, err.Error(), "authentication failure")
+	require.Equal(t, 1, attempts)
+
+	_, err = c.Connection(context.Background())
+	require.ErrorIs(t, err, errAdminCredentialsRejected)
+
+	c.resetAuthCircuit()
+	err = c.withAuthCircuit(context.Background(), func(context.Context) error {
+		return nil
+	})
+	require.NoError(t, err)
+
+	// The circuit closes once the cool-down expired.
+	c.AuthFailureCooldown = time.Nanosecond
+	err = c.withAuthCircuit(context.Background(), failing)
+	require.ErrorIs(t, err, errAdminCredentialsRejected)
+	time.Sleep(time.Millisecond)
+	require.NoError(t, c.checkAuthCircuit())
+}
+
+func TestAuthCircuit_RereadCredentials(t *testing.T) {
+	unauthorizedErr := &neo4j.Neo4jError{Code: "Neo.ClientError.Security.Unauthorized", Msg: "The client is unauthorized due to authentication failure."}
+
+	for name, authConfig := range map[string]func(c *Neo4j){
+		"token file": func(c *Neo4j) {
+			c.AuthMethod = authMethodBearer
+			c.TokenFile = "/var/run/secrets/neo4j/token"
+		},
+		"kerberos": func(c *Neo4j) {
+			c.AuthMethod = authMethodKerberos
+			c.KerberosCCache = "/tmp/krb5cc_vault"
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			c := new()
+			c.Initialized = true
+			c.AuthFailureCooldown = time.Minute
+			authConfig(c)
+
+			// The first rejection makes the driver re-read the credentials,
+			// which are accepted.
+			attempts := 0
+			err := c.withAuthCircuit(context.Background(), func(context.Context) error {
+				attempts++
+				if attempts == 1 {
+					return unauthorizedErr
+				}
+				return nil
+			})
+			require.NoError(t, err)
+			require.Equal(t, 2, attempts)
+			require.NoError(t, c.checkAuthCircuit())
+
+			// The re-read credentials are rejected as well.
+			attempts = 0
+			failing := func(context.Context) error {
+				attempts++
+				return unauthorizedErr
+			}
+			err = c.withAuthCircuit(context.Background(), failing)
+			require.ErrorIs(t, err, errAdminCredentialsRejected)
+			require.ErrorIs(t, err, unauthorizedErr)
+			require.Equal(t, 2, attempts)
+
+			err = c.withAuthCircuit(context.Background(), failing)
+			require.ErrorIs(t, err, errAdminCredentialsRejected)
+			require.Equal(t, 2, attempts)
+		})
+	}
+}
@@ -2,23 +2,47 @@ package neo4j
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
 	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
 	"sync"
 	"time"
 
+	"github.com/hashicorp/go-hclog"
 	"github.com/hashicorp/vault/sdk/database/helper/connutil"
 	"github.com/hashicorp/vault/sdk/database/helper/dbutil"
 	"github.com/mitchellh/mapstructure"
 	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j/config"
 )
 
+// defaultDatabase is the database administration commands run against unless
+// configured otherwise.
+const defaultDatabase = "system"
+
 type neo4jConnectionProducer struct {
 	ConnectionURL string `json:"connection_url" structs:"connection_url" mapstructure:"connection_url"`
 	WriteConcern  string `json:"write_concern" structs:"write_concern" mapstructure:"write_concern"`
+	Database      string `json:"database" structs:"database" mapstructure:"database"`
 
 	Username string `json:"username" structs:"username" mapstructure:"username"`
 	Password string `json:"password" structs:"password" mapstructure:"password"`
 
+	AuthMethod string `json:"auth_method" structs:"auth_method" mapstructure:"auth_method"`
+	Token      string `json:"token"       structs:"-"           mapstructure:"token"`
+	TokenFile  string `json:"token_file"  structs:"token_file"  mapstructure:"token_file"`
+
+	KerberosKeytab           string `json:"kerberos_keytab"            structs:"kerberos_keytab"            mapstructure:"kerberos_keytab"`
+	KerberosCCache           string `json:"kerberos_ccache"            structs:"kerberos_ccache"            mapstructure:"kerberos_ccache"`
+	KerberosPrincipal        string `json:"kerberos_principal"         structs:"kerberos_principal"         mapstructure:"kerberos_principal"`
+	KerberosServicePrincipal string `json:"kerberos_service_principal" structs:"kerberos_service_principal" mapstructure:"kerberos_service_principal"`
+	KerberosConfig           string `json:"kerberos_config"            structs:"kerberos_config"            mapstructure:"kerberos_config"`
+
 	TLSCertificateKeyData []byte `json:"tls_certificate_key" structs:"-" mapstructure:"tls_certificate_key"`
 	TLSCAData             []byte `json:"tls_ca"              structs:"-" mapstructure:"tls_ca"`
 
@@ -26,18 +50,108 @@ type neo4jConnectionProducer struct {
 	ConnectTimeout         time.Duration `json:"connect_timeout"          structs:"-" mapstructure:"connect_timeout"`
 	ServerSelectionTimeout time.Duration `json:"server_selection_timeout" structs:"-" mapstructure:"server_selection_timeout"`
 
+	MaxTransactionRetryTime      time.Duration `json:"max_transaction_retry_time"     structs:"-" mapstructure:"max_transaction_retry_time"`
+	ConnectionAcquisitionTimeout time.Duration `json:"connection_acquisition_timeout" structs:"-" mapstructure:"connection_acquisition_timeout"`
+	MaxConnectionPoolSize        int           `json:"max_connection_pool_size"       structs:"-" mapstructure:"max_connection_pool_size"`
+	MaxConnectionLifetime        time.Duration `json:"max_connection_lifetime"        structs:"-" mapstructure:"max_connection_lifetime"`
+
+	RetryMaxAttempts    int           `json:"retry_max_attempts"     structs:"-" mapstructure:"retry_max_attempts"`
+	RetryMaxElapsedTime time.Duration `json:"retry_max_elapsed_time" structs:"-" mapstructure:"retry_max_elapsed_time"`
+
+	PropagationTimeout  time.Duration `json:"propagation_timeout"   structs:"-" mapstructure:"propagation_timeout"`
+	HealthCheckInterval time.Duration `json:"health_check_interval" structs:"-" mapstructure:"health_check_interval"`
+
+	MetadataDatabase        string        `json:"metadata_database"         structs:"metadata_database"   mapstructure:"metadata_database"`
+	ExpiredUserAction       string        `json:"expired_user_action"       structs:"expired_user_action" mapstructure:"expired_user_action"`
+	ExpirationSweepInterval time.Duration `json:"expiration_sweep_interval" structs:"-"                   mapstructure:"expiration_sweep_interval"`
+
+	ReconcileInterval        time.Duration `json:"reconcile_interval"         structs:"-" mapstructure:"reconcile_interval"`
+	ReconcileMaxAge          time.Duration `json:"reconcile_max_age"          structs:"-" mapstructure:"reconcile_max_age"`
+	ReconcileAction          string        `json:"reconcile_action"           structs:"-" mapstructure:"reconcile_action"`
+	ReconcileUsernamePattern string        `json:"reconcile_username_pattern" structs:"-" mapstructure:"reconcile_username_pattern"`
+
+	AuthFailureCooldown time.Duration `json:"auth_failure_cooldown" structs:"-" mapstructure:"auth_failure_cooldown"`
+
 	Initialized   bool
 	RawConfig     map[string]interface{}
 	Type          string
 	clientOptions neo4j.SessionConfig
+	tlsConfig     *tls.Config
 	client        neo4j.DriverWithContext
-	sync.Mutex
+	server        *serverInfo
+
+	// leaders are the drivers connected directly to the writer of a database,
+	// see runCommandOnLeader.
+	leaders    map[string]neo4j.DriverWithContext
+	leaderURLs map[string]string
+
+	reconcileUsernameRegex *regexp.Regexp
+
+	logger      hclog.Logger
+	retryLogger hclog.Logger
+
+	healthCheck     *healthCheck
+	healthCheckLock sync.Mutex
+
+	authCircuit authCircuit
+
+	sync.RWMutex
 }
 
+// secretValues returns the configured secrets mapped to their replacements,
+// to be scrubbed from errors and logs.
 func (c *neo4jConnectionProducer) secretValues() map[string]string {
-	return map[string]string{
+	secrets := map[string]string{
 		c.Password: "[password]",
+		c.Token:    "[token]",
+	}
+
+	// The password may also be embedded in the connection_url, in its raw or
+	// escaped form.
+	if u, err := url.Parse(c.ConnectionURL); err == nil && u.User != nil {
+		if password, ok := u.User.Password(); ok {
+			secrets[password] = "[password]"
+			if _, escaped, ok := strings.Cut(u.User.String(), ":"); ok {
+				secrets[escaped] = "[password]"
+			}
+		}
+	}
+
+	rest := c.TLSCertificateKeyData
+	for {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		if strings.Contains(block.Type, "PRIVATE KEY") {
+			secrets[strings.TrimSpace(string(pem.EncodeToMemory(block)))] = "[tls_certificate_key]"
+		}
 	}
+
+	return secrets
+}
+
+// sanitizeError replaces the configured secrets as well as the given request
+// secrets, e.g. the password of a new user, in the message of err.
+func (c *neo4jConnectionProducer) sanitizeError(err error, requestSecrets map[string]string) error {
+	if err == nil {
+		return nil
+	}
+
+	message := err.Error()
+	for _, secrets := range []map[string]string{c.secretValues(), requestSecrets} {
+		for find, replace := range secrets {
+			if find == "" {
+				continue
+			}
+			message = strings.ReplaceAll(message, find, replace)
+		}
+	}
+	if message == err.Error() {
+		return err
+	}
+	return errors.New(message)
 }
 
 func (c *neo4jConnectionProducer) getConnectionURL() (connURL string) {
@@ -48,31 +162,71 @@ func (c *neo4jConnectionProducer) getConnectionURL() (connURL string) {
 	return connURL
 }
 
-// Connection creates or returns an existing a database connection. If the session fails
-// on a ping check, the session will be closed and then re-created.
-// This method does locks the mutex on its own.
+// Connection returns a new session of the shared driver, creating the driver
+// first if there is none. The driver pools its connections, which are checked
+// by the driver itself, so sessions are cheap and concurrent callers only hold
+// a read lock. See checkHealth for how broken drivers are replaced.
 func (c *neo4jConnectionProducer) Connection(ctx context.Context) (neo4j.SessionWithContext, error) {
+	return c.connectionTo(ctx, c.clientOptions.DatabaseName)
+}
+
+// connectionTo is like Connection, for a session on the given database.
+func (c *neo4jConnectionProducer) connectionTo(ctx context.Context, database string) (neo4j.SessionWithContext, error) {
 	if !c.Initialized {
 		return nil, connutil.ErrNotInitialized
 	}
+	if err := c.checkAuthCircuit(); err != nil {
+		return nil, err
+	}
 
-	c.Mutex.Lock()
-	defer c.Mutex.Unlock()
+	c.RLock()
+	client := c.client
+	c.RUnlock()
 
-	if c.client != nil {
-		if err := c.client.VerifyConnectivity(ctx); err == nil {
-			return c.client.NewSession(ctx, c.clientOptions), nil
+	if client == nil {
+		var err error
+		client, err = c.ensureClient(ctx)
+		if err != nil {
+			return nil, err
 		}
-		// Ignore error on purpose since we want to re-create a session
-		_ = c.client.Close(ctx)
+	}
+	return client.NewSession(ctx, c.sessionConfig(database)), nil
+}
+
+// ensureClient creates the driver unless another caller has done so in the
+// meantime, and detects the server with it.
+func (c *neo4jConnectionProducer) ensureClient(ctx context.Context) (neo4j.DriverWithContext, error) {
+	c.Lock()
+	defer c.Unlock()
+
+	if c.client != nil {
+		return c.client, nil
 	}
 
 	client, err := c.createClient(ctx)
 	if err != nil {
 		return nil, err
 	}
+	c.logger.Debug("created driver")
 	c.client = client
-	return c.client.NewSession(ctx, c.clientOptions), nil
+
+	if c.server == nil {
+		if err := c.observeAuthError(c.detectServer(ctx, client)); err != nil {
+			c.logger.Warn("failed to detect the server, assuming it supports all features", "error", c.sanitizeError(err, nil))
+		}
+	}
+	return client, nil
+}
+
+// sessionConfig returns the configuration of admin sessions on the given
+// database. Only sessions on the admin database share bookmarks.
+func (c *neo4jConnectionProducer) sessionConfig(database string) neo4j.SessionConfig {
+	sessionConfig := c.clientOptions
+	if database != c.clientOptions.DatabaseName {
+		sessionConfig.DatabaseName = database
+		sessionConfig.BookmarkManager = nil
+	}
+	return sessionConfig
 }
 
 func (c *neo4jConnectionProducer) createClient(ctx context.Context) (neo4j.DriverWithContext, error) {
@@ -80,7 +234,7 @@ func (c *neo4jConnectionProducer) createClient(ctx context.Context) (neo4j.Drive
 		return nil, fmt.Errorf("failed to create client: connection producer is not initialized")
 	}
 
-	client, err := neo4j.NewDriverWithContext(c.ConnectionURL, neo4j.BasicAuth(c.Username, c.Password, ""))
+	client, err := neo4j.NewDriverWithContext(c.ConnectionURL, c.authTokenManager(), c.configureDriver)
 
 	if err != nil {
 		return nil, err
@@ -88,11 +242,86 @@ func (c *neo4jConnectionProducer) createClient(ctx context.Context) (neo4j.Drive
 	return client, nil
 }
 
+// configureDriver applies the connection settings to the driver configuration.
+// Unset settings keep the driver defaults.
+func (c *neo4jConnectionProducer) configureDriver(config *config.Config) {
+	config.TlsConfig = c.tlsConfig
+
+	if c.ConnectTimeout > 0 {
+		config.SocketConnectTimeout = c.ConnectTimeout
+	}
+	if c.MaxTransactionRetryTime > 0 {
+		config.MaxTransactionRetryTime = c.MaxTransactionRetryTime
+	}
+	// Selecting a server is part of acquiring a connection, so the server
+	// selection timeout bounds the acquisition unless it is set explicitly.
+	switch {
+	case c.ConnectionAcquisitionTimeout > 0:
+		config.ConnectionAcquisitionTimeout = c.ConnectionAcquisitionTimeout
+	case c.ServerSelectionTimeout > 0:
+		config.ConnectionAcquisitionTimeout = c.ServerSelectionTimeout
+	}
+	if c.MaxConnectionPoolSize > 0 {
+		config.MaxConnectionPoolSize = c.MaxConnectionPoolSize
+	}
+	if c.MaxConnectionLifetime > 0 {
+		config.MaxConnectionLifetime = c.MaxConnectionLifetime
+	}
+}
+
+// verifyConnectivity checks the connectivity of the client within the server
+// selection timeout.
+func (c *neo4jConnectionProducer) verifyConnectivity(ctx context.Context, client neo4j.DriverWithContext) error {
+	if c.ServerSelectionTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.ServerSelectionTimeout)
+		defer cancel()
+	}
+	return client.VerifyConnectivity(ctx)
+}
+
+// retryPolicy returns the policy for retrying commands that fail with
+// transient errors.
+func (c *neo4jConnectionProducer) retryPolicy() retryPolicy {
+	policy := retryPolicy{
+		maxAttempts:    c.RetryMaxAttempts,
+		maxElapsedTime: c.RetryMaxElapsedTime,
+		initialBackoff: retryInitialBackoff,
+		maxBackoff:     retryMaxBackoff,
+		logger:         c.retryLogger,
+		beforeRetry:    c.checkHealthAfter,
+		sanitizeError: func(err error) error {
+			return c.sanitizeError(err, nil)
+		},
+	}
+	if policy.maxAttempts == 0 {
+		policy.maxAttempts = defaultRetryMaxAttempts
+	}
+	if policy.maxElapsedTime == 0 {
+		policy.maxElapsedTime = defaultRetryMaxElapsedTime
+	}
+	return policy
+}
+
+// withSocketTimeout bounds a single round trip to the database by the socket
+// timeout.
+func (c *neo4jConnectionProducer) withSocketTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if c.SocketTimeout > 0 {
+		return context.WithTimeout(ctx, c.SocketTimeout)
+	}
+	return context.WithCancel(ctx)
+}
+
 // Close terminates the database connection.
 func (c *neo4jConnectionProducer) Close() error {
+	// The health check takes the lock, stop it before taking it.
+	c.stopHealthCheck()
+
 	c.Lock()
 	defer c.Unlock()
 
+	c.closeLeaders()
+
 	if c.client != nil {
 		ctx, cancel := context.WithTimeout(context.Background(), 1*time.Minute)
 		defer cancel()
@@ -105,13 +334,50 @@ func (c *neo4jConnectionProducer) Close() error {
 
 	return nil
 }
+
+// makeClientOpts returns the configuration of admin sessions. Their bookmarks
+// are shared, so that admin commands are causally chained and the cluster can
+// be waited on until it has caught up with them.
 func (c *neo4jConnectionProducer) makeClientOpts() (neo4j.SessionConfig, error) {
-	// TODO implement other parameters
-	return neo4j.SessionConfig{DatabaseName: "neo4j"}, nil
+	return neo4j.SessionConfig{
+		DatabaseName:    c.Database,
+		BookmarkManager: neo4j.NewBookmarkManager(neo4j.BookmarkManagerConfig{}),
+	}, nil
+}
+
+// loadDatabase determines the database administration commands run against:
+// the database option, the path of the connection_url or the system database.
+func (c *neo4jConnectionProducer) loadDatabase() error {
+	u, err := url.Parse(c.ConnectionURL)
+	if err != nil {
+		return fmt.Errorf("failed to parse connection_url: %w", err)
+	}
+
+	urlDatabase := strings.Trim(u.Path, "/")
+	switch {
+	case urlDatabase == "":
+	case c.Database == "":
+		c.Database = urlDatabase
+	case c.Database != urlDatabase:
+		return fmt.Errorf("database %q does not match the database %q of the connection_url", c.Database, urlDatabase)
+	}
+
+	if c.Database == "" {
+		c.Database = defaultDatabase
+	}
+	return nil
 }
 
 func (c *neo4jConnectionProducer) loadConfig(cfg map[string]interface{}) error {
-	err := mapstructure.WeakDecode(cfg, c)
+	decoder, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
+		DecodeHook:       mapstructure.StringToTimeDurationHookFunc(),
+		WeaklyTypedInput: true,
+		Result:           c,
+	})
+	if err != nil {
+		return err
+	}
+	err = decoder.Decode(cfg)
 	if err != nil {
 		return err
 	}
@@ -129,6 +395,63 @@ func (c *neo4jConnectionProducer) loadConfig(cfg map[string]interface{}) error {
 	if c.ServerSelectionTimeout < 0 {
 		return fmt.Errorf("server_selection_timeout must be >= 0")
 	}
+	if c.MaxTransactionRetryTime < 0 {
+		return fmt.Errorf("max_transaction_retry_time must be >= 0")
+	}
+	if c.ConnectionAcquisitionTimeout < 0 {
+		return fmt.Errorf("connection_acquisition_timeout must be >= 0")
+	}
+	if c.MaxConnectionPoolSize < 0 {
+		return fmt.Errorf("max_connection_pool_size must be >= 0")
+	}
+	if c.MaxConnectionLifetime < 0 {
+		return fmt.Errorf("max_connection_lifetime must be >= 0")
+	}
+	if c.RetryMaxAttempts < 0 {
+		return fmt.Errorf("retry_max_attempts must be >= 0")
+	}
+	if c.RetryMaxElapsedTime < 0 {
+		return fmt.Errorf("retry_max_elapsed_time must be >= 0")
+	}
+	if c.PropagationTimeout < 0 {
+		return fmt.Errorf("propagation_timeout must be >= 0")
+	}
+	if c.HealthCheckInterval < 0 {
+		return fmt.Errorf("health_check_interval must be >= 0")
+	}
+
+	if c.MetadataDatabase != "" && !databaseNameRegex.MatchString(c.MetadataDatabase) {
+		return fmt.Errorf("metadata_database %q is not a valid database name", c.MetadataDatabase)
+	}
+	switch c.ExpiredUserAction {
+	case "":
+		c.ExpiredUserAction = expiredUserActionSuspend
+	case expiredUserActionSuspend, expiredUserActionDrop:
+	default:
+		return fmt.Errorf("expired_user_action must be %q or %q", expiredUserActionSuspend, expiredUserActionDrop)
+	}
+	if c.ExpirationSweepInterval < 0 {
+		return fmt.Errorf("expiration_sweep_interval must be >= 0")
+	}
+	if c.ExpirationSweepInterval == 0 {
+		c.ExpirationSweepInterval = defaultExpirationSweepInterval
+	}
+
+	err = c.loadAuthConfig()
+	if err != nil {
+		return err
+	}
+
+	usernameTemplate, _ := cfg["username_template"].(string)
+	err = c.loadReconcileConfig(usernameTemplate)
+	if err != nil {
+		return err
+	}
+
+	err = c.loadDatabase()
+	if err != nil {
+		return err
+	}
 
 	opts, err := c.makeClientOpts()
 	if err != nil {
@@ -137,5 +460,57 @@ func (c *neo4jConnectionProducer) loadConfig(cfg map[string]interface{}) error {
 
 	c.clientOptions = opts
 
+	tlsConfig, err := c.getTLSConfig()
+	if err != nil {
+		return err
+	}
+
+	c.tlsConfig = tlsConfig
+
 	return nil
 }
+
+// getTLSConfig builds the TLS configuration of the driver from the PEM encoded
+// CA bundle and client certificate/key. It returns nil if neither is set.
+func (c *neo4jConnectionProducer) getTLSConfig() (*tls.Config, error) {
+	if len(c.TLSCAData) == 0 && len(c.TLSCertificateKeyData) == 0 {
+		return nil, nil
+	}
+
+	u, err := url.Parse(c.ConnectionURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse connection_url: %w", err)
+	}
+
+	switch u.Scheme {
+	case "bolt+s", "neo4j+s":
+	case "bolt+ssc", "neo4j+ssc":
+		if len(c.TLSCAData) > 0 {
+			return nil, fmt.Errorf("tls_ca cannot be used with the %q scheme, which skips certificate verification; use %q instead", u.Scheme, u.Scheme[:len(u.Scheme)-2])
+		}
+	default:
+		return nil, fmt.Errorf("tls_ca and tls_certificate_key require an encrypted connection_url scheme (bolt+s or neo4j+s), got %q", u.Scheme)
+	}
+
+	tlsConfig := &tls.Config{}
+
+	if len(c.TLSCAData) > 0 {
+		tlsConfig.RootCAs = x509.NewCertPool()
+
+		ok := tlsConfig.RootCAs.AppendCertsFromPEM(c.TLSCAData)
+		if !ok {
+			return nil, errors.New("failed to parse tls_ca: no PEM encoded certificates found")
+		}
+	}
+
+	if len(c.TLSCertificateKeyData) > 0 {
+		certificate, err := tls.X509KeyPair(c.TLSCertificateKeyData, c.TLSCertificateKeyData)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse tls_certificate_key: %w", err)
+		}
+
+		tlsConfig.Certificates = append(tlsConfig.Certificates, certificate)
+	}
+
+	return tlsConfig, nil
+}
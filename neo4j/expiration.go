@@ -0,0 +1,124 @@
+package neo4j
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+const (
+	expiredUserActionSuspend = "suspend"
+	expiredUserActionDrop    = "drop"
+)
+
+// defaultExpirationSweepInterval is how often expired users are swept unless
+// configured otherwise.
+const defaultExpirationSweepInterval = time.Minute
+
+// expirationEnabled reports whether the expiration of users is recorded in the
+// metadata database.
+func (m *Neo4j) expirationEnabled() bool {
+	return m.MetadataDatabase != ""
+}
+
+// recordExpiration stores the expiration of the user in the metadata database,
+// so that the user can be swept if Vault fails to revoke it.
+func (m *Neo4j) recordExpiration(ctx context.Context, username string, expiration time.Time) error {
+	if !m.expirationEnabled() {
+		return nil
+	}
+
+	recordExpirationCmd := recordExpirationCommand{
+		Username:   username,
+		Expiration: expiration,
+	}
+	var command, params = recordExpirationCmd.transform()
+	if err := m.runCommandWithRetryOn(ctx, m.MetadataDatabase, command, params); err != nil {
+		return fmt.Errorf("failed to record the expiration of user %q: %w", username, err)
+	}
+	return nil
+}
+
+// deleteExpiration removes the expiration of the user from the metadata
+// database.
+func (m *Neo4j) deleteExpiration(ctx context.Context, username string) error {
+	if !m.expirationEnabled() {
+		return nil
+	}
+
+	deleteExpirationCmd := deleteExpirationCommand{
+		Username: username,
+	}
+	var command, params = deleteExpirationCmd.transform()
+	if err := m.runCommandWithRetryOn(ctx, m.MetadataDatabase, command, params); err != nil {
+		return fmt.Errorf("failed to delete the expiration of user %q: %w", username, err)
+	}
+	return nil
+}
+
+// SweepExpiredUsers suspends or drops, depending on the expired_user_action,
+// every user created by the plugin whose recorded expiration has passed. This
+// enforces the expiration inside neo4j for users Vault failed to revoke. Users
+// that fail to be swept don't stop the others. The reconciler runs it every
+// expiration_sweep_interval.
+func (m *Neo4j) SweepExpiredUsers(ctx context.Context) error {
+	if !m.expirationEnabled() {
+		return fmt.Errorf("metadata_database is not configured")
+	}
+
+	showExpiredUsersCmd := showExpiredUsersCommand{
+		Now: time.Now(),
+	}
+	var query, params = showExpiredUsersCmd.transform()
+	records, err := m.runQueryWithRetryOn(ctx, m.MetadataDatabase, query, params)
+	if err != nil {
+		return fmt.Errorf("failed to look up expired users: %w", err)
+	}
+
+	var errs []error
+	for _, record := range records {
+		username, ok := record.Values[0].(string)
+		if !ok {
+			continue
+		}
+
+		if err := m.sweepExpiredUser(ctx, username); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (m *Neo4j) sweepExpiredUser(ctx context.Context, username string) error {
+	if m.ExpiredUserAction == expiredUserActionDrop {
+		if err := m.dropUser(ctx, username); err != nil {
+			return fmt.Errorf("failed to drop expired user %q: %w", username, err)
+		}
+		m.usersLogger.Info("dropped expired user", "username", username)
+		return nil
+	}
+
+	alterUserStatusCmd := alterUserStatusCommand{
+		Username:  username,
+		Suspended: true,
+		IfExists:  true,
+	}
+	if err := alterUserStatusCmd.checkSupport(m.serverInfo(ctx)); err != nil {
+		return fmt.Errorf("failed to suspend expired user %q: %w", username, err)
+	}
+	var command, params = alterUserStatusCmd.transform()
+	if err := m.runCommandWithRetry(ctx, command, params); err != nil {
+		return fmt.Errorf("failed to suspend expired user %q: %w", username, err)
+	}
+	m.usersLogger.Info("suspended expired user", "username", username)
+
+	markExpirationSweptCmd := markExpirationSweptCommand{
+		Username: username,
+	}
+	command, params = markExpirationSweptCmd.transform()
+	if err := m.runCommandWithRetryOn(ctx, m.MetadataDatabase, command, params); err != nil {
+		return fmt.Errorf("failed to mark expired user %q as suspended: %w", username, err)
+	}
+	return nil
+}
@@ -0,0 +1,111 @@
+package neo4j
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+)
+
+// healthCheck periodically checks the connectivity of the driver if a
+// health_check_interval is configured.
+type healthCheck struct {
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// checkHealthAfter checks the health of the driver after a command failed with
+// a connectivity error. Other errors say nothing about the driver.
+func (c *neo4jConnectionProducer) checkHealthAfter(ctx context.Context, err error) {
+	var connectivityErr *neo4j.ConnectivityError
+	if !errors.As(err, &connectivityErr) {
+		return
+	}
+	_ = c.checkHealth(ctx)
+}
+
+// checkHealth verifies the connectivity of the driver. A driver failing the
+// check is closed, so that the next session creates a new one. The check runs
+// without holding the lock, and a driver replaced by another caller in the
+// meantime is left alone.
+func (c *neo4jConnectionProducer) checkHealth(ctx context.Context) error {
+	c.RLock()
+	client := c.client
+	c.RUnlock()
+
+	if client == nil {
+		return nil
+	}
+	if err := c.checkAuthCircuit(); err != nil {
+		return err
+	}
+
+	err := c.observeAuthError(c.verifyConnectivity(ctx, client))
+	if err == nil {
+		return nil
+	}
+	c.logger.Warn("connectivity check failed, recreating the driver", "error", c.sanitizeError(err, nil))
+
+	c.Lock()
+	replaced := c.client == client
+	if replaced {
+		c.client = nil
+	}
+	c.Unlock()
+
+	// Concurrent checks of the same driver close it only once. Ignore error on
+	// purpose since the driver is replaced anyway
+	if replaced {
+		_ = client.Close(ctx)
+	}
+	return err
+}
+
+// startHealthCheck starts checking the health of the driver in the background
+// if a health_check_interval is configured.
+func (c *neo4jConnectionProducer) startHealthCheck() {
+	if c.HealthCheckInterval == 0 {
+		return
+	}
+
+	c.healthCheckLock.Lock()
+	defer c.healthCheckLock.Unlock()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	h := &healthCheck{
+		cancel: cancel,
+		done:   make(chan struct{}),
+	}
+	c.healthCheck = h
+
+	go func() {
+		defer close(h.done)
+
+		ticker := time.NewTicker(c.HealthCheckInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				_ = c.checkHealth(ctx)
+			}
+		}
+	}()
+}
+
+// stopHealthCheck stops the background health check, if running, and waits
+// for it to finish.
+func (c *neo4jConnectionProducer) stopHealthCheck() {
+	c.healthCheckLock.Lock()
+	defer c.healthCheckLock.Unlock()
+
+	if c.healthCheck == nil {
+		return
+	}
+	c.healthCheck.cancel()
+	<-c.healthCheck.done
+	c.healthCheck = nil
+}
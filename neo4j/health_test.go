@@ -0,0 +1,166 @@
+package neo4j
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeDriver counts the calls of the driver methods used by the connection
+// producer. Calling any other method panics.
+type fakeDriver struct {
+	neo4j.DriverWithContext
+
+	verifyErr error
+	// verifying, if set, blocks VerifyConnectivity until it is closed.
+	verifying chan struct{}
+
+	sessions atomic.Int32
+	verifies atomic.Int32
+	closes   atomic.Int32
+}
+
+func (d *fakeDriver) NewSession(context.Context, neo4j.SessionConfig) neo4j.SessionWithContext {
+	d.sessions.Add(1)
+	return nil
+}
+
+func (d *fakeDriver) VerifyConnectivity(context.Context) error {
+	d.verifies.Add(1)
+	if d.verifying != nil {
+		<-d.verifying
+	}
+	return d.verifyErr
+}
+
+func (d *fakeDriver) Close(context.Context) error {
+	d.closes.Add(1)
+	return nil
+}
+
+func newFakeConnection(driver *fakeDriver) *Neo4j {
+	c := new()
+	c.Initialized = true
+	c.AuthFailureCooldown = time.Minute
+	c.client = driver
+	return c
+}
+
+func TestConnection_ReusesDriver(t *testing.T) {
+	driver := &fakeDriver{}
+	c := newFakeConnection(driver)
+
+	for i := 0; i < 3; i++ {
+		_, err := c.Connection(context.Background())
+		require.NoError(t, err)
+	}
+
+	// Sessions come from the shared driver without verifying its connectivity.
+	require.Equal(t, int32(3), driver.sessions.Load())
+	require.Equal(t, int32(0), driver.verifies.Load())
+	require.Equal(t, int32(0), driver.closes.Load())
+	require.Same(t, driver, c.client)
+}
+
+func TestConnection_ReadLock(t *testing.T) {
+	driver := &fakeDriver{}
+	c := newFakeConnection(driver)
+
+	// Callers that needed the write lock would block until the read lock is
+	// released.
+	c.RLock()
+	defer c.RUnlock()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, _ = c.Connection(context.Background())
+		}()
+	}
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("concurrent callers blocked on the read lock")
+	}
+	require.Equal(t, int32(10), driver.sessions.Load())
+}
+
+func TestCheckHealth_ReplacesFailedDriverOnce(t *testing.T) {
+	connectivityErr := &neo4j.ConnectivityError{Inner: errors.New("connection refused")}
+	driver := &fakeDriver{
+		verifyErr: connectivityErr,
+		verifying: make(chan struct{}),
+	}
+	c := newFakeConnection(driver)
+
+	const callers = 5
+	errs := make(chan error, callers)
+	for i := 0; i < callers; i++ {
+		go func() {
+			errs <- c.checkHealth(context.Background())
+		}()
+	}
+
+	// Let all callers check the same driver before any of them fails it.
+	require.Eventually(t, func() bool {
+		return driver.verifies.Load() == callers
+	}, 5*time.Second, time.Millisecond)
+	close(driver.verifying)
+
+	for i := 0; i < callers; i++ {
+		require.ErrorIs(t, <-errs, connectivityErr)
+	}
+	require.Equal(t, int32(1), driver.closes.Load())
+	require.Nil(t, c.client)
+
+	// A check of the failed driver finishing late leaves its replacement
+	// alone.
+	replacement := &fakeDriver{}
+	stale := &fakeDriver{verifyErr: connectivityErr}
+	c.client = stale
+	stale.verifying = make(chan struct{})
+	staleErr := make(chan error, 1)
+	go func() {
+		staleErr <- c.checkHealth(context.Background())
+	}()
+	require.Eventually(t, func() bool {
+		return stale.verifies.Load() == 1
+	}, 5*time.Second, time.Millisecond)
+
+	c.Lock()
+	c.client = replacement
+	c.Unlock()
+	close(stale.verifying)
+
+	require.ErrorIs(t, <-staleErr, connectivityErr)
+	require.Same(t, replacement, c.client)
+	require.Equal(t, int32(0), stale.closes.Load())
+	require.Equal(t, int32(0), replacement.closes.Load())
+}
+
+func TestCheckHealthAfter(t *testing.T) {
+	driver := &fakeDriver{}
+	c := newFakeConnection(driver)
+
+	// Errors other than connectivity errors say nothing about the driver.
+	c.checkHealthAfter(context.Background(), &neo4j.Neo4jError{Code: "Neo.TransientError.General.DatabaseUnavailable"})
+	require.Equal(t, int32(0), driver.verifies.Load())
+
+	c.checkHealthAfter(context.Background(), &neo4j.ConnectivityError{Inner: errors.New("connection reset")})
+	require.Equal(t, int32(1), driver.verifies.Load())
+	require.Same(t, driver, c.client)
+}
@@ -19,10 +19,10 @@ const (
 	Neo4jPassword = "a_secure_password"
 )
 
-// PrepareTestContainer calls PrepareTestContainerWithDatabase without a
-// database name value, which results in configuring a database named "test"
+// PrepareTestContainer calls PrepareTestContainerWithDatabase with the system
+// database, which is where administration commands run
 func PrepareTestContainer(t *testing.T, version string) (cleanup func(), retURL string) {
-	return PrepareTestContainerWithDatabase(t, version, "default")
+	return PrepareTestContainerWithDatabase(t, version, "system")
 }
 
 // PrepareTestContainerWithDatabase configures a test container with a given
@@ -37,7 +37,11 @@ func PrepareTestContainerWithDatabase(t *testing.T, version, dbName string) (fun
 		ImageRepo:     "docker.io/library/neo4j",
 		ImageTag:      version,
 		Ports:         []string{"7687/tcp"},
-		Env:           []string{fmt.Sprintf("NEO4J_AUTH=%s/%s", Neo4jUsername, Neo4jPassword)},
+		Env: []string{
+			fmt.Sprintf("NEO4J_AUTH=%s/%s", Neo4jUsername, Neo4jPassword),
+			// Roles and privileges are only available in the enterprise edition
+			"NEO4J_ACCEPT_LICENSE_AGREEMENT=yes",
+		},
 	})
 	if err != nil {
 		t.Fatalf("could not start docker neo4j: %s", err)
@@ -0,0 +1,190 @@
+package neo4j
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+)
+
+// Error codes of servers that can't write to a database because another member
+// of the cluster is its leader.
+const (
+	notALeaderCode          = "Neo.ClientError.Cluster.NotALeader"
+	forbiddenOnReadOnlyCode = "Neo.ClientError.General.ForbiddenOnReadOnlyDatabase"
+	leaderCloseTimeout      = 10 * time.Second
+)
+
+// notALeaderError is returned by the transactions of direct connections when
+// the server is not the leader. It deliberately doesn't unwrap the error of the
+// server, so that the driver returns it at once instead of retrying on the same
+// server until max_transaction_retry_time.
+type notALeaderError struct {
+	err error
+}
+
+func (e *notALeaderError) Error() string {
+	return e.err.Error()
+}
+
+// isNotALeaderError reports whether a write failed because the server it ran
+// on is not the leader of the database.
+func isNotALeaderError(err error) bool {
+	var leaderErr *notALeaderError
+	if errors.As(err, &leaderErr) {
+		return true
+	}
+
+	var executionLimit *neo4j.TransactionExecutionLimit
+	if errors.As(err, &executionLimit) {
+		if len(executionLimit.Errors) == 0 {
+			return false
+		}
+		return isNotALeaderError(executionLimit.Errors[len(executionLimit.Errors)-1])
+	}
+
+	var neo4jErr *neo4j.Neo4jError
+	if errors.As(err, &neo4jErr) {
+		return neo4jErr.Code == notALeaderCode || neo4jErr.Code == forbiddenOnReadOnlyCode
+	}
+	return false
+}
+
+// isDirect reports whether the connection_url points at a single server. Unlike
+// the routing driver of neo4j:// URLs, the driver of a direct connection can't
+// send writes to the leader on its own.
+func (c *neo4jConnectionProducer) isDirect() bool {
+	return strings.HasPrefix(c.ConnectionURL, "bolt")
+}
+
+// runCommandOnLeader runs a command on a direct connection. If the server of
+// the connection_url is not the leader of the database, e.g. a follower behind
+// a load balancer, the leader is looked up in the routing table and the command
+// is run there instead. The leader is remembered for the following commands
+// until it fails.
+func (m *Neo4j) runCommandOnLeader(ctx context.Context, database, command string, params map[string]any) error {
+	leader := m.leader(database)
+	err := m.runDirectCommand(ctx, leader, database, command, params)
+	if err == nil {
+		return nil
+	}
+
+	var connectivityErr *neo4j.ConnectivityError
+	if !isNotALeaderError(err) && !(leader != nil && errors.As(err, &connectivityErr)) {
+		return err
+	}
+
+	leader, discoverErr := m.discoverLeader(ctx, database)
+	if discoverErr != nil {
+		return fmt.Errorf("%w; failed to discover the leader: %w", err, discoverErr)
+	}
+	return m.runDirectCommand(ctx, leader, database, command, params)
+}
+
+// runDirectCommand runs a command on the given leader, or on the server of the
+// connection_url if the leader is not known.
+func (m *Neo4j) runDirectCommand(ctx context.Context, leader neo4j.DriverWithContext, database, command string, params map[string]any) error {
+	var session neo4j.SessionWithContext
+	if leader != nil {
+		session = leader.NewSession(ctx, m.sessionConfig(database))
+	} else {
+		var err error
+		session, err = m.connectionTo(ctx, database)
+		if err != nil {
+			return err
+		}
+	}
+	defer session.Close(ctx)
+
+	writeCtx, cancel := m.withSocketTimeout(ctx)
+	defer cancel()
+	return executeWrite(session, writeCtx, command, params, true)
+}
+
+// leader returns the driver connected to the leader of the database, or nil if
+// the leader is not known.
+func (c *neo4jConnectionProducer) leader(database string) neo4j.DriverWithContext {
+	c.RLock()
+	defer c.RUnlock()
+	return c.leaders[database]
+}
+
+// leaderURL returns the URL of the leader of the database, or "" if the leader
+// is not known.
+func (c *neo4jConnectionProducer) leaderURL(database string) string {
+	c.RLock()
+	defer c.RUnlock()
+	return c.leaderURLs[database]
+}
+
+// discoverLeader looks up the leader of the database in the routing table of
+// the server of the connection_url and connects to it directly.
+func (c *neo4jConnectionProducer) discoverLeader(ctx context.Context, database string) (neo4j.DriverWithContext, error) {
+	session, err := c.connectionTo(ctx, c.clientOptions.DatabaseName)
+	if err != nil {
+		return nil, err
+	}
+	defer session.Close(ctx)
+
+	showWriterCmd := showWriterCommand{
+		Database: database,
+	}
+	var query, params = showWriterCmd.transform()
+
+	readCtx, cancel := c.withSocketTimeout(ctx)
+	defer cancel()
+	records, err := executeRead(session, readCtx, query, params)
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, fmt.Errorf("the routing table has no writer for database %q", database)
+	}
+	address, _ := records[0].Values[0].(string)
+
+	memberURL, err := c.memberURL(address)
+	if err != nil {
+		return nil, err
+	}
+	leader, err := neo4j.NewDriverWithContext(memberURL, c.authTokenManager(), c.configureDriver)
+	if err != nil {
+		return nil, err
+	}
+
+	c.Lock()
+	previous := c.leaders[database]
+	if c.leaders == nil {
+		c.leaders = make(map[string]neo4j.DriverWithContext)
+	}
+	c.leaders[database] = leader
+	if c.leaderURLs == nil {
+		c.leaderURLs = make(map[string]string)
+	}
+	c.leaderURLs[database] = memberURL
+	c.Unlock()
+
+	if previous != nil {
+		_ = previous.Close(ctx)
+	}
+	c.logger.Info("rerouting admin commands to the leader", "database", database, "address", address)
+	return leader, nil
+}
+
+// closeLeaders closes the drivers connected to the leaders. The caller must
+// hold the lock.
+func (c *neo4jConnectionProducer) closeLeaders() {
+	if len(c.leaders) == 0 {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), leaderCloseTimeout)
+	defer cancel()
+	for _, leader := range c.leaders {
+		_ = leader.Close(ctx)
+	}
+	c.leaders = nil
+	c.leaderURLs = nil
+}
@@ -0,0 +1,69 @@
+package neo4j
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsNotALeaderError(t *testing.T) {
+	tests := map[string]struct {
+		err      error
+		expected bool
+	}{
+		"nil": {
+			err: nil,
+		},
+		"not a leader": {
+			err:      &neo4j.Neo4jError{Code: "Neo.ClientError.Cluster.NotALeader"},
+			expected: true,
+		},
+		"read only database": {
+			err:      fmt.Errorf("failed: %w", &neo4j.Neo4jError{Code: "Neo.ClientError.General.ForbiddenOnReadOnlyDatabase"}),
+			expected: true,
+		},
+		"rerouted": {
+			err:      &notALeaderError{err: &neo4j.Neo4jError{Code: "Neo.ClientError.Cluster.NotALeader"}},
+			expected: true,
+		},
+		"transaction execution limit": {
+			err: &neo4j.TransactionExecutionLimit{
+				Cause:  "timeout",
+				Errors: []error{&neo4j.Neo4jError{Code: "Neo.ClientError.Cluster.NotALeader"}},
+			},
+			expected: true,
+		},
+		"transient error": {
+			err: &neo4j.Neo4jError{Code: "Neo.TransientError.General.DatabaseUnavailable"},
+		},
+		"other error": {
+			err: errors.New("boom"),
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			require.Equal(t, test.expected, isNotALeaderError(test.err))
+		})
+	}
+}
+
+func TestIsDirect(t *testing.T) {
+	tests := map[string]bool{
+		"bolt://10.0.0.1:7687":             true,
+		"bolt+s://10.0.0.1:7687":           true,
+		"neo4j://cluster.example.com:7687": false,
+		"neo4j+s://cluster.example.com":    false,
+	}
+
+	for connectionURL, expected := range tests {
+		t.Run(connectionURL, func(t *testing.T) {
+			c := new()
+			c.ConnectionURL = connectionURL
+			require.Equal(t, expected, c.isDirect())
+		})
+	}
+}
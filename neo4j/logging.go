@@ -0,0 +1,41 @@
+package neo4j
+
+import (
+	"os"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+)
+
+// logLevelEnv is the environment variable holding the log level of Vault,
+// which plugin processes inherit from the Vault process.
+const logLevelEnv = "VAULT_LOG_LEVEL"
+
+// newLogger returns the root logger of the plugin. Vault parses the JSON logs
+// written to stderr by plugins and re-emits them through its own log pipeline,
+// filtered by its log level. The VAULT_LOG_LEVEL also filters logs within the
+// plugin, so that no debug logs are produced unless Vault wants them.
+func newLogger() hclog.Logger {
+	level := hclog.LevelFromString(os.Getenv(logLevelEnv))
+	if level == hclog.NoLevel {
+		level = hclog.Trace
+	}
+
+	return hclog.New(&hclog.LoggerOptions{
+		Name:       neo4jTypeName,
+		Level:      level,
+		Output:     os.Stderr,
+		JSONFormat: true,
+	})
+}
+
+// logUserOperation logs the outcome of a user operation along with its
+// duration. Passwords must never be passed as args.
+func logUserOperation(logger hclog.Logger, operation string, start time.Time, err error, args ...interface{}) {
+	args = append(args, "duration", time.Since(start))
+	if err != nil {
+		logger.Error(operation+" failed", append(args, "error", err)...)
+		return
+	}
+	logger.Info(operation, args...)
+}
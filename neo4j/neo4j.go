@@ -2,12 +2,11 @@ package neo4j
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
-	"io"
-	"log"
-	"strings"
+	"sync"
+	"time"
 
+	"github.com/hashicorp/go-hclog"
 	"github.com/hashicorp/go-secure-stdlib/strutil"
 	"github.com/hashicorp/vault/sdk/database/dbplugin/v5"
 	"github.com/hashicorp/vault/sdk/database/helper/dbutil"
@@ -26,6 +25,12 @@ type Neo4j struct {
 	*neo4jConnectionProducer
 
 	usernameProducer template.StringTemplate
+
+	logger      hclog.Logger
+	usersLogger hclog.Logger
+
+	reconciler     *reconciler
+	reconcilerLock sync.Mutex
 }
 
 var (
@@ -38,19 +43,25 @@ var (
 // New returns a new neo4j instance
 
 func New() (interface{}, error) {
-	log.Println("Running neo4j databse plugin")
 	db := new()
+	db.logger.Info("running neo4j database plugin")
 	dbType := dbplugin.NewDatabaseErrorSanitizerMiddleware(db, db.secretValues)
 	return dbType, nil
 }
 
 func new() *Neo4j {
+	logger := newLogger()
+
 	connProducer := &neo4jConnectionProducer{
-		Type: neo4jTypeName,
+		Type:        neo4jTypeName,
+		logger:      logger.Named("connection"),
+		retryLogger: logger.Named("retry"),
 	}
 
 	return &Neo4j{
 		neo4jConnectionProducer: connProducer,
+		logger:                  logger,
+		usersLogger:             logger.Named("users"),
 	}
 }
 
@@ -60,15 +71,20 @@ func (m *Neo4j) Type() (string, error) {
 }
 
 func (p *Neo4j) PluginVersion() logical.PluginVersion {
-	log.Println("Reading plugin version")
+	p.logger.Debug("reading plugin version")
 	return logical.PluginVersion{Version: ReportedVersion}
 }
 
 func (m *Neo4j) Initialize(ctx context.Context, req dbplugin.InitializeRequest) (dbplugin.InitializeResponse, error) {
+	// The reconciler and the health check take the connection lock, stop
+	// them before taking it.
+	m.stopReconciler()
+	m.stopHealthCheck()
+
 	m.Lock()
 	defer m.Unlock()
 
-	m.RawConfig = req.Config
+	m.RawConfig = withoutSecrets(req.Config)
 
 	usernameTemplate, err := strutil.GetString(req.Config, "username_template")
 	if err != nil {
@@ -93,6 +109,16 @@ func (m *Neo4j) Initialize(ctx context.Context, req dbplugin.InitializeRequest)
 	if err != nil {
 		return dbplugin.InitializeResponse{}, err
 	}
+	m.server = nil
+	m.closeLeaders()
+	m.resetAuthCircuit()
+
+	// Drop the driver of the previous configuration, the next session
+	// creates one with the new settings.
+	if m.client != nil {
+		_ = m.client.Close(ctx)
+		m.client = nil
+	}
 
 	// Set initialized to true at this point since all fields are set,
 	// and the connection can be established at a later time.
@@ -104,85 +130,412 @@ func (m *Neo4j) Initialize(ctx context.Context, req dbplugin.InitializeRequest)
 			return dbplugin.InitializeResponse{}, fmt.Errorf("failed to verify connection: %w", err)
 		}
 
-		err = client.VerifyConnectivity(ctx)
+		err = m.neo4jConnectionProducer.verifyConnectivity(ctx, client)
 		if err != nil {
 			_ = client.Close(ctx) // Try to prevent any sort of resource leak
 			return dbplugin.InitializeResponse{}, fmt.Errorf("failed to verify connection: %w", err)
 		}
+
+		err = m.neo4jConnectionProducer.detectServer(ctx, client)
+		if err != nil {
+			_ = client.Close(ctx)
+			return dbplugin.InitializeResponse{}, fmt.Errorf("failed to verify connection: %w", err)
+		}
 		m.neo4jConnectionProducer.client = client
 	}
 
+	m.startReconciler()
+	m.startHealthCheck()
+
 	resp := dbplugin.InitializeResponse{
-		Config: req.Config,
+		Config: responseConfig(req.Config),
 	}
 	return resp, nil
 }
 
+// Close stops the reconciler and the health check and terminates the database connection.
+func (m *Neo4j) Close() error {
+	m.stopReconciler()
+	return m.neo4jConnectionProducer.Close()
+}
+
 func (m *Neo4j) NewUser(ctx context.Context, req dbplugin.NewUserRequest) (dbplugin.NewUserResponse, error) {
+	start := time.Now()
+
+	username, err := m.newUser(ctx, req)
+	err = m.sanitizeError(err, map[string]string{req.Password: "[password]"})
+	logUserOperation(m.usersLogger, "create user", start, err, "username", username, "role", req.UsernameConfig.RoleName)
+	if err != nil {
+		return dbplugin.NewUserResponse{}, err
+	}
+	m.awaitPropagation(ctx, username)
+
+	resp := dbplugin.NewUserResponse{
+		Username: username,
+	}
+	return resp, nil
+}
+
+// newUser creates the user of the request and returns its username.
+func (m *Neo4j) newUser(ctx context.Context, req dbplugin.NewUserRequest) (string, error) {
 	if len(req.Statements.Commands) == 0 {
-		return dbplugin.NewUserResponse{}, dbutil.ErrEmptyCreationStatement
+		return "", dbutil.ErrEmptyCreationStatement
 	}
 
 	username, err := m.usernameProducer.Generate(req.UsernameConfig)
 	if err != nil {
-		return dbplugin.NewUserResponse{}, err
+		return "", err
+	}
+
+	if !isJSONStatement(req.Statements.Commands[0]) {
+		if err := m.createUserWithStatements(ctx, username, req); err != nil {
+			return username, err
+		}
+		if err := m.recordExpiration(ctx, username, req.Expiration); err != nil {
+			return username, m.rollbackUser(ctx, username, false, err)
+		}
+		return username, nil
 	}
 
-	// Unmarshal statements.CreationStatements into neo4jRoles
-	var neo4jCS neo4jStatement
-	err = json.Unmarshal([]byte(req.Statements.Commands[0]), &neo4jCS)
+	neo4jCS, err := parseCreationStatement(req.Statements.Commands[0])
 	if err != nil {
-		return dbplugin.NewUserResponse{}, err
+		return username, err
+	}
+	if neo4jCS.legacy {
+		m.usersLogger.Warn("creation statement uses the deprecated role document format, please migrate to version 1 of the creation statement schema", "role", req.UsernameConfig.RoleName)
 	}
 
-	// Default to "admin" if no db provided
-	if neo4jCS.DB == "" {
-		neo4jCS.DB = "admin"
+	createUserCmd := createUserCommand{
+		Username:     username,
+		Password:     req.Password,
+		Roles:        neo4jCS.Roles,
+		HomeDatabase: neo4jCS.HomeDatabase,
+		CopyRoleFrom: neo4jCS.CopyRoleFrom,
+		Privileges:   neo4jCS.Privileges,
+
+		AuthProviders:     neo4jCS.resolveAuthProviders(username, req.UsernameConfig.DisplayName),
+		DisableNativeAuth: !neo4jCS.nativeAuth(),
+	}
+	if err := createUserCmd.checkSupport(m.serverInfo(ctx)); err != nil {
+		return username, fmt.Errorf("invalid creation statement: %w", err)
 	}
 
-	if len(neo4jCS.Roles) == 0 {
-		return dbplugin.NewUserResponse{}, fmt.Errorf("roles array is required in creation statement")
+	if neo4jCS.HomeDatabase != "" {
+		if err := m.validateDatabaseExists(ctx, neo4jCS.HomeDatabase); err != nil {
+			return username, err
+		}
 	}
 
-	createUserCmd := createUserCommand{
-		Username: username,
-		Password: req.Password,
-		Roles:    neo4jCS.Roles.toStandardRolesArray(),
+	if neo4jCS.CopyRoleFrom != "" {
+		if err := m.validateRoleExists(ctx, neo4jCS.CopyRoleFrom); err != nil {
+			return username, err
+		}
+	}
+
+	if err := m.createUser(ctx, createUserCmd); err != nil {
+		return username, err
 	}
+	// The response of NewUser can't carry metadata, log the IDs the user is
+	// bound to instead.
+	for _, provider := range createUserCmd.AuthProviders {
+		m.usersLogger.Info("bound user to auth provider", "username", username, "provider", provider.Provider, "id", provider.ID)
+	}
+
+	if err := m.recordExpiration(ctx, username, req.Expiration); err != nil {
+		return username, m.rollbackUser(ctx, username, true, err)
+	}
+	return username, nil
+}
+
+// createUser creates the user and grants it every role of the command. If the
+// command copies a template role or has privileges, a role dedicated to the
+// user is created as a copy of the template and granted the privileges.
+// If any of the steps fails the user and its role are dropped again, so that
+// a failed NewUser never leaves a partially provisioned user behind.
+func (m *Neo4j) createUser(ctx context.Context, createUserCmd createUserCommand) error {
 	var command, params = createUserCmd.transform()
+	if err := m.runCommandWithRetry(ctx, command, params); err != nil {
+		return err
+	}
+
+	roles := createUserCmd.Roles
+	hasEphemeralRole := createUserCmd.CopyRoleFrom != "" || len(createUserCmd.Privileges) > 0
+	if hasEphemeralRole {
+		role := ephemeralRoleName(createUserCmd.Username)
+		if err := m.createEphemeralRole(ctx, role, createUserCmd.CopyRoleFrom, createUserCmd.Privileges); err != nil {
+			return m.rollbackUser(ctx, createUserCmd.Username, hasEphemeralRole, fmt.Errorf("failed to create role %q: %w", role, err))
+		}
+		roles = append(roles, role)
+	}
+
+	for _, role := range roles {
+		grantRoleCmd := grantRoleCommand{
+			Username: createUserCmd.Username,
+			Role:     role,
+		}
+		command, params = grantRoleCmd.transform()
+		if err := m.runCommandWithRetry(ctx, command, params); err != nil {
+			return m.rollbackUser(ctx, createUserCmd.Username, hasEphemeralRole, fmt.Errorf("failed to grant role %q: %w", role, err))
+		}
+	}
 
+	return nil
+}
+
+// createEphemeralRole creates the role dedicated to a single lease, as a copy
+// of the template role if one is given, and grants it the privileges.
+func (m *Neo4j) createEphemeralRole(ctx context.Context, role, copyOf string, privileges []graphPrivilege) error {
+	createRoleCmd := createRoleCommand{
+		Name:   role,
+		CopyOf: copyOf,
+	}
+	var command, params = createRoleCmd.transform()
 	if err := m.runCommandWithRetry(ctx, command, params); err != nil {
-		return dbplugin.NewUserResponse{}, err
+		return err
 	}
 
-	resp := dbplugin.NewUserResponse{
+	for i, privilege := range privileges {
+		grantPrivilegeCmd := grantPrivilegeCommand{
+			Role:      role,
+			Privilege: privilege,
+		}
+		command, params = grantPrivilegeCmd.transform()
+		if err := m.runCommandWithRetry(ctx, command, params); err != nil {
+			return fmt.Errorf("failed to grant privileges[%d]: %w", i, err)
+		}
+	}
+
+	return nil
+}
+
+// dropEphemeralRole drops the role dedicated to the user, if there is one.
+// Community Edition has no roles, so nothing is dropped there.
+func (m *Neo4j) dropEphemeralRole(ctx context.Context, username string) error {
+	dropRoleCmd := dropRoleCommand{
+		Name:     ephemeralRoleName(username),
+		IfExists: true,
+	}
+	if err := dropRoleCmd.checkSupport(m.serverInfo(ctx)); err != nil {
+		return nil
+	}
+	var command, params = dropRoleCmd.transform()
+	return m.runCommandWithRetry(ctx, command, params)
+}
+
+// dropUser drops a user outside of a revocation, along with its ephemeral role
+// and recorded expiration. Users that no longer exist are ignored.
+func (m *Neo4j) dropUser(ctx context.Context, username string) error {
+	dropUserCmd := dropUserCommand{
 		Username: username,
+		IfExists: true,
 	}
-	return resp, nil
+	var command, params = dropUserCmd.transform()
+	if err := m.runCommandWithRetry(ctx, command, params); err != nil {
+		return err
+	}
+	if err := m.dropEphemeralRole(ctx, username); err != nil {
+		return fmt.Errorf("failed to drop the role of user %q: %w", username, err)
+	}
+	return m.deleteExpiration(ctx, username)
 }
 
-func (m *Neo4j) DeleteUser(ctx context.Context, req dbplugin.DeleteUserRequest) (dbplugin.DeleteUserResponse, error) {
-	dropUserCommand := dropUserCommand{
-		Username: req.Username,
+// validateDatabaseExists returns an error if there is no database with the
+// given name.
+func (m *Neo4j) validateDatabaseExists(ctx context.Context, database string) error {
+	showDatabaseCmd := showDatabaseCommand{
+		Name: database,
+	}
+	var query, params = showDatabaseCmd.transform()
+	records, err := m.runQueryWithRetry(ctx, query, params)
+	if err != nil {
+		return fmt.Errorf("failed to look up home database %q: %w", database, err)
+	}
+	if len(records) == 0 {
+		return fmt.Errorf("home database %q does not exist", database)
+	}
+	return nil
+}
+
+// validateRoleExists returns an error if there is no role with the given name.
+func (m *Neo4j) validateRoleExists(ctx context.Context, role string) error {
+	showRoleCmd := showRoleCommand{
+		Name: role,
+	}
+	var query, params = showRoleCmd.transform()
+	records, err := m.runQueryWithRetry(ctx, query, params)
+	if err != nil {
+		return fmt.Errorf("failed to look up template role %q: %w", role, err)
+	}
+	if len(records) == 0 {
+		return fmt.Errorf("template role %q does not exist", role)
+	}
+	return nil
+}
+
+// createUserWithStatements runs the cypher creation statements of the request
+// in order. The username, password and expiration are bound as parameters.
+// If any of the statements after the one creating the user fails, the user is
+// dropped again. If the creating statement itself fails, e.g. because the
+// username is taken, the user isn't ours to drop.
+func (m *Neo4j) createUserWithStatements(ctx context.Context, username string, req dbplugin.NewUserRequest) error {
+	statements, err := parseCypherStatements(req.Statements.Commands)
+	if err != nil {
+		return err
+	}
+
+	params := map[string]any{
+		"username":   username,
+		"password":   req.Password,
+		"expiration": req.Expiration.Format(time.RFC3339),
+	}
+	creating := creatingStatementIndex(statements)
+	for i, statement := range statements {
+		if err := m.runCommandWithRetry(ctx, statement, params); err != nil {
+			if i <= creating {
+				return err
+			}
+			return m.rollbackUser(ctx, username, false, err)
+		}
+	}
+
+	return nil
+}
+
+// rollbackUser drops a user whose creation failed half-way, along with its
+// ephemeral role, and returns the original error, along with the drop error if
+// the rollback failed as well.
+func (m *Neo4j) rollbackUser(ctx context.Context, username string, dropEphemeralRole bool, cause error) error {
+	dropUserCmd := dropUserCommand{
+		Username: username,
+		IfExists: true,
 	}
-	var command, params = dropUserCommand.transform()
+	var command, params = dropUserCmd.transform()
 	if err := m.runCommandWithRetry(ctx, command, params); err != nil {
+		return fmt.Errorf("%w; additionally failed to roll back user %q: %w", cause, username, err)
+	}
+
+	if dropEphemeralRole {
+		if err := m.dropEphemeralRole(ctx, username); err != nil {
+			return fmt.Errorf("%w; additionally failed to roll back the role of user %q: %w", cause, username, err)
+		}
+	}
+	return cause
+}
+
+func (m *Neo4j) DeleteUser(ctx context.Context, req dbplugin.DeleteUserRequest) (dbplugin.DeleteUserResponse, error) {
+	start := time.Now()
+
+	err := m.deleteUser(ctx, req)
+	err = m.sanitizeError(err, nil)
+	logUserOperation(m.usersLogger, "delete user", start, err, "username", req.Username)
+	if err != nil {
 		return dbplugin.DeleteUserResponse{}, err
 	}
 	return dbplugin.DeleteUserResponse{}, nil
 }
 
+func (m *Neo4j) deleteUser(ctx context.Context, req dbplugin.DeleteUserRequest) error {
+	if len(req.Statements.Commands) > 0 {
+		if err := m.deleteUserWithStatements(ctx, req); err != nil {
+			return err
+		}
+	} else {
+		// The user may already have been dropped by the expiration sweep or
+		// the reconciler.
+		dropUserCommand := dropUserCommand{
+			Username: req.Username,
+			IfExists: true,
+		}
+		var command, params = dropUserCommand.transform()
+		if err := m.runCommandWithRetry(ctx, command, params); err != nil {
+			return err
+		}
+	}
+
+	if err := m.dropEphemeralRole(ctx, req.Username); err != nil {
+		return fmt.Errorf("failed to drop the role of user %q: %w", req.Username, err)
+	}
+	return m.deleteExpiration(ctx, req.Username)
+}
+
+// deleteUserWithStatements runs the cypher revocation statements of the request
+// in order instead of the default DROP USER. The username is bound as a
+// parameter.
+func (m *Neo4j) deleteUserWithStatements(ctx context.Context, req dbplugin.DeleteUserRequest) error {
+	statements, err := parseCypherStatements(req.Statements.Commands)
+	if err != nil {
+		return err
+	}
+
+	params := map[string]any{
+		"username": req.Username,
+	}
+	for _, statement := range statements {
+		if err := m.runCommandWithRetry(ctx, statement, params); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (m *Neo4j) UpdateUser(ctx context.Context, req dbplugin.UpdateUserRequest) (dbplugin.UpdateUserResponse, error) {
+	start := time.Now()
+
+	err := m.updateUser(ctx, req)
+	var requestSecrets map[string]string
 	if req.Password != nil {
-		err := m.changeUserPassword(ctx, req.Username, req.Password.NewPassword)
+		requestSecrets = map[string]string{req.Password.NewPassword: "[password]"}
+	}
+	err = m.sanitizeError(err, requestSecrets)
+	logUserOperation(m.usersLogger, "update user", start, err, "username", req.Username, "change_password", req.Password != nil, "change_expiration", req.Expiration != nil)
+	if err != nil {
 		return dbplugin.UpdateUserResponse{}, err
 	}
+	m.awaitPropagation(ctx, req.Username)
 	return dbplugin.UpdateUserResponse{}, nil
 }
 
-func (m *Neo4j) changeUserPassword(ctx context.Context, username, password string) error {
+func (m *Neo4j) updateUser(ctx context.Context, req dbplugin.UpdateUserRequest) error {
+	if req.Password != nil {
+		var err error
+		if m.isRootRotation(req.Username) {
+			err = m.rotateRootPassword(ctx, req.Password.NewPassword, req.Password.Statements)
+		} else {
+			err = m.changeUserPassword(ctx, req.Username, req.Password.NewPassword, req.Password.Statements)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if req.Expiration != nil {
+		if len(req.Expiration.Statements.Commands) > 0 {
+			params := map[string]any{
+				"username":   req.Username,
+				"expiration": req.Expiration.NewExpiration.Format(time.RFC3339),
+			}
+			err := m.runUpdateStatements(ctx, req.Username, req.Expiration.Statements, params, false)
+			if err != nil {
+				return err
+			}
+		}
+		if err := m.recordExpiration(ctx, req.Username, req.Expiration.NewExpiration); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// changeUserPassword runs the cypher rotation statements with the username and
+// password bound as parameters, or the default ALTER USER if none are given.
+func (m *Neo4j) changeUserPassword(ctx context.Context, username, password string, rotationStatements dbplugin.Statements) error {
+	if len(rotationStatements.Commands) > 0 {
+		params := map[string]any{
+			"username": username,
+			"password": password,
+		}
+		return m.runUpdateStatements(ctx, username, rotationStatements, params, true)
+	}
 
-	// Currently doesn't support custom statements for changing the user's password
 	changeUserCmd := &updateUserCommand{
 		Username: username,
 		Password: password,
@@ -192,54 +545,140 @@ func (m *Neo4j) changeUserPassword(ctx context.Context, username, password strin
 	return m.runCommandWithRetry(ctx, command, params)
 }
 
-// runCommandWithRetry runs a command and retries once more if there's a failure
-// on the first attempt. This should be called with the lock held
-func (m *Neo4j) runCommandWithRetry(ctx context.Context, command string, params map[string]any) error {
-	// Get the client
-	client, err := m.Connection(ctx)
+// runUpdateStatements runs the cypher statements of an update in order. The
+// ACTIVATE and SUSPEND keywords are accepted as statements to change the
+// status of the user. ACTIVATE is only accepted if allowActivate is set: lease
+// holders can renew their own leases, so renew_statements must never activate
+// a suspended user.
+func (m *Neo4j) runUpdateStatements(ctx context.Context, username string, updateStatements dbplugin.Statements, params map[string]any, allowActivate bool) error {
+	statements, err := parseCypherStatements(updateStatements.Commands)
 	if err != nil {
 		return err
 	}
+	if !allowActivate {
+		for _, statement := range statements {
+			if suspended, ok := parseStatusKeyword(statement); ok && !suspended {
+				return fmt.Errorf("%s is not allowed in renew_statements, as lease holders can renew their own leases; use the rotation_statements of a static role instead", statusKeywordActivate)
+			}
+		}
+	}
 
-	defer client.Close(ctx)
-
-	err = executeWrite(client, ctx, command, params)
-
-	// Error check on the first attempt
-	switch {
-	case err == nil:
-		return nil
-	case err == io.EOF, strings.Contains(err.Error(), "EOF"):
-		// Call getConnection to reset and retry query if we get an EOF error on first attempt.
-		client, err = m.Connection(ctx)
-		if err != nil {
-			return err
+	for _, statement := range statements {
+		command, commandParams := statement, params
+		if suspended, ok := parseStatusKeyword(statement); ok {
+			alterUserStatusCmd := alterUserStatusCommand{
+				Username:  username,
+				Suspended: suspended,
+			}
+			if err := alterUserStatusCmd.checkSupport(m.serverInfo(ctx)); err != nil {
+				return err
+			}
+			command, commandParams = alterUserStatusCmd.transform()
 		}
-		err = executeWrite(client, ctx, command, params)
-		if err != nil {
+
+		if err := m.runCommandWithRetry(ctx, command, commandParams); err != nil {
 			return err
 		}
-	default:
+	}
+	return nil
+}
+
+// runCommandWithRetry runs a command, retrying it according to the retry policy
+// of the connection if it fails with a transient error.
+func (m *Neo4j) runCommandWithRetry(ctx context.Context, command string, params map[string]any) error {
+	return m.runCommandWithRetryOn(ctx, m.clientOptions.DatabaseName, command, params)
+}
+
+// runCommandWithRetryOn is like runCommandWithRetry, running the command on
+// the given database.
+func (m *Neo4j) runCommandWithRetryOn(ctx context.Context, database, command string, params map[string]any) error {
+	return m.withAuthCircuit(ctx, func(ctx context.Context) error {
+		return m.retryPolicy().run(ctx, func(ctx context.Context) error {
+			return m.runCommand(ctx, database, command, params)
+		})
+	})
+}
+
+// runCommand runs a command once in a new session, which is closed afterwards.
+func (m *Neo4j) runCommand(ctx context.Context, database, command string, params map[string]any) error {
+	if m.isDirect() {
+		return m.runCommandOnLeader(ctx, database, command, params)
+	}
+
+	session, err := m.connectionTo(ctx, database)
+	if err != nil {
 		return err
 	}
+	defer session.Close(ctx)
 
-	return nil
+	writeCtx, cancel := m.withSocketTimeout(ctx)
+	defer cancel()
+	return executeWrite(session, writeCtx, command, params, false)
+}
+
+// runQueryWithRetry runs a read query, retrying it according to the retry
+// policy of the connection if it fails with a transient error.
+func (m *Neo4j) runQueryWithRetry(ctx context.Context, query string, params map[string]any) ([]*neo4j.Record, error) {
+	return m.runQueryWithRetryOn(ctx, m.clientOptions.DatabaseName, query, params)
 }
 
-func executeWrite(client neo4j.SessionWithContext, ctx context.Context, command string, params map[string]any) error {
+// runQueryWithRetryOn is like runQueryWithRetry, running the query on the
+// given database.
+func (m *Neo4j) runQueryWithRetryOn(ctx context.Context, database, query string, params map[string]any) ([]*neo4j.Record, error) {
+	var records []*neo4j.Record
+	err := m.withAuthCircuit(ctx, func(ctx context.Context) error {
+		return m.retryPolicy().run(ctx, func(ctx context.Context) error {
+			session, err := m.connectionTo(ctx, database)
+			if err != nil {
+				return err
+			}
+			defer session.Close(ctx)
+
+			readCtx, cancel := m.withSocketTimeout(ctx)
+			defer cancel()
+			records, err = executeRead(session, readCtx, query, params)
+			return err
+		})
+	})
+	return records, err
+}
+
+// executeWrite runs a command in a write transaction. On direct connections,
+// errors of servers that are not the leader are returned as notALeaderError, as
+// retrying on the same server is futile.
+func executeWrite(client neo4j.SessionWithContext, ctx context.Context, command string, params map[string]any, direct bool) error {
 	_, err := client.ExecuteWrite(ctx, func(transaction neo4j.ManagedTransaction) (any, error) {
 		result, err := transaction.Run(ctx,
 			command,
 			params)
-		if err != nil {
-			return nil, err
-		}
-
-		if result.Next(ctx) {
+		if err == nil && result.Next(ctx) {
 			return result.Record().Values[0], nil
 		}
+		if err == nil {
+			err = result.Err()
+		}
 
-		return nil, result.Err()
+		if direct && isNotALeaderError(err) {
+			return nil, &notALeaderError{err: err}
+		}
+		return nil, err
 	})
 	return err
 }
+
+func executeRead(client neo4j.SessionWithContext, ctx context.Context, query string, params map[string]any) ([]*neo4j.Record, error) {
+	records, err := client.ExecuteRead(ctx, func(transaction neo4j.ManagedTransaction) (any, error) {
+		result, err := transaction.Run(ctx,
+			query,
+			params)
+		if err != nil {
+			return nil, err
+		}
+
+		return result.Collect(ctx)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return records.([]*neo4j.Record), nil
+}
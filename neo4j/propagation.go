@@ -0,0 +1,137 @@
+package neo4j
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+)
+
+// propagationPollInterval is the interval members are polled at until the
+// user is visible on them.
+const propagationPollInterval = 100 * time.Millisecond
+
+// awaitPropagation waits for the changes to the user to propagate through the
+// cluster. The changes are committed at this point, so a timeout only delays
+// the response and is logged rather than failing the request.
+func (m *Neo4j) awaitPropagation(ctx context.Context, username string) {
+	start := time.Now()
+	if err := m.waitForPropagation(ctx, username); err != nil {
+		m.usersLogger.Warn("changes to the user did not propagate to the whole cluster", "username", username, "error", m.sanitizeError(err, nil))
+		return
+	}
+	if m.PropagationTimeout > 0 {
+		m.usersLogger.Debug("changes to the user propagated", "username", username, "duration", time.Since(start))
+	}
+}
+
+// waitForPropagation waits, up to the propagation_timeout, until the latest
+// changes to the user are visible on every member of the cluster. Every member
+// is asked for the user with the bookmarks of the admin transactions, so
+// followers only answer once they have caught up with the leader.
+func (m *Neo4j) waitForPropagation(ctx context.Context, username string) error {
+	if m.PropagationTimeout == 0 {
+		return nil
+	}
+
+	server := m.serverInfo(ctx)
+	if server.isCommunity() {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, m.PropagationTimeout)
+	defer cancel()
+
+	bookmarks, err := m.clientOptions.BookmarkManager.GetBookmarks(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get bookmarks: %w", err)
+	}
+
+	showServersCmd := showServersCommand{
+		Legacy: !server.atLeast(5, 0),
+	}
+	var query, params = showServersCmd.transform()
+	records, err := m.runQueryWithRetry(ctx, query, params)
+	if err != nil {
+		return fmt.Errorf("failed to list the members of the cluster: %w", err)
+	}
+	if len(records) <= 1 {
+		return nil
+	}
+
+	for _, record := range records {
+		address, ok := record.Values[0].(string)
+		if !ok {
+			continue
+		}
+		if err := m.waitForMember(ctx, address, bookmarks, username); err != nil {
+			return fmt.Errorf("user %q is not visible on %s after %s: %w", username, address, m.PropagationTimeout, err)
+		}
+	}
+	return nil
+}
+
+// waitForMember polls a single member of the cluster until the user is
+// visible on it.
+func (m *Neo4j) waitForMember(ctx context.Context, address string, bookmarks neo4j.Bookmarks, username string) error {
+	memberURL, err := m.memberURL(address)
+	if err != nil {
+		return err
+	}
+
+	client, err := neo4j.NewDriverWithContext(memberURL, m.authTokenManager(), m.configureDriver)
+	if err != nil {
+		return err
+	}
+	defer client.Close(ctx)
+
+	session := client.NewSession(ctx, neo4j.SessionConfig{
+		DatabaseName: m.clientOptions.DatabaseName,
+		Bookmarks:    bookmarks,
+		AccessMode:   neo4j.AccessModeRead,
+	})
+	defer session.Close(ctx)
+
+	showUserCmd := showUserCommand{
+		Username: username,
+	}
+	var query, params = showUserCmd.transform()
+
+	ticker := time.NewTicker(propagationPollInterval)
+	defer ticker.Stop()
+
+	for {
+		records, err := executeRead(session, ctx, query, params)
+		if err == nil && len(records) > 0 {
+			return nil
+		}
+		if isAuthError(err) {
+			// Polling with rejected credentials would lock out the admin user.
+			return m.observeAuthError(err)
+		}
+		if err == nil {
+			err = fmt.Errorf("user %q does not exist", username)
+		}
+
+		select {
+		case <-ctx.Done():
+			return err
+		case <-ticker.C:
+		}
+	}
+}
+
+// memberURL returns the URL connecting directly to the member of the cluster
+// at the given address, with the encryption of the connection_url.
+func (c *neo4jConnectionProducer) memberURL(address string) (string, error) {
+	u, err := url.Parse(c.ConnectionURL)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse connection_url: %w", err)
+	}
+
+	scheme := "bolt" + strings.TrimPrefix(strings.TrimPrefix(u.Scheme, "neo4j"), "bolt")
+	return scheme + "://" + address, nil
+}
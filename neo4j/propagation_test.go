@@ -0,0 +1,28 @@
+package neo4j
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemberURL(t *testing.T) {
+	tests := map[string]string{
+		"neo4j://cluster.example.com:7687":     "bolt://10.0.0.2:7687",
+		"neo4j+s://cluster.example.com:7687":   "bolt+s://10.0.0.2:7687",
+		"neo4j+ssc://cluster.example.com:7687": "bolt+ssc://10.0.0.2:7687",
+		"bolt://10.0.0.1:7687":                 "bolt://10.0.0.2:7687",
+		"bolt+s://10.0.0.1:7687":               "bolt+s://10.0.0.2:7687",
+	}
+
+	for connectionURL, expected := range tests {
+		t.Run(connectionURL, func(t *testing.T) {
+			c := new()
+			c.ConnectionURL = connectionURL
+
+			actual, err := c.memberURL("10.0.0.2:7687")
+			require.NoError(t, err)
+			require.Equal(t, expected, actual)
+		})
+	}
+}
@@ -0,0 +1,209 @@
+package neo4j
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+// defaultReconcileUsernamePattern matches the usernames generated by the
+// default username template, capturing the time the user was created.
+const defaultReconcileUsernamePattern = `^v-.*-[a-zA-Z0-9]{20}-(?P<unix_time>[0-9]+)$`
+
+// reconcileTimeGroup is the named group of the username pattern capturing the
+// unix time the user was created.
+const reconcileTimeGroup = "unix_time"
+
+// reconciler periodically cleans up users created by the plugin whose leases
+// were lost, e.g. after restoring the storage of Vault or failed revocations,
+// and sweeps expired users if their expiration is recorded.
+type reconciler struct {
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// startReconciler starts the background reconciler if a reconcile_interval or
+// a metadata_database is configured. Both run on their own interval.
+func (m *Neo4j) startReconciler() {
+	if m.ReconcileInterval == 0 && !m.expirationEnabled() {
+		return
+	}
+
+	m.reconcilerLock.Lock()
+	defer m.reconcilerLock.Unlock()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	r := &reconciler{
+		cancel: cancel,
+		done:   make(chan struct{}),
+	}
+	m.reconciler = r
+
+	go func() {
+		defer close(r.done)
+
+		// A nil channel never fires, which disables the respective task.
+		var reconcileTicks, sweepTicks <-chan time.Time
+		if m.ReconcileInterval > 0 {
+			ticker := time.NewTicker(m.ReconcileInterval)
+			defer ticker.Stop()
+			reconcileTicks = ticker.C
+		}
+		if m.expirationEnabled() {
+			ticker := time.NewTicker(m.ExpirationSweepInterval)
+			defer ticker.Stop()
+			sweepTicks = ticker.C
+		}
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-reconcileTicks:
+				if err := m.reconcile(ctx); err != nil {
+					m.usersLogger.Error("failed to reconcile users", "error", m.sanitizeError(err, nil))
+				}
+			case <-sweepTicks:
+				if err := m.SweepExpiredUsers(ctx); err != nil {
+					m.usersLogger.Error("failed to sweep expired users", "error", m.sanitizeError(err, nil))
+				}
+			}
+		}
+	}()
+}
+
+// stopReconciler stops the background reconciler, if running, and waits for
+// it to finish.
+func (m *Neo4j) stopReconciler() {
+	m.reconcilerLock.Lock()
+	defer m.reconcilerLock.Unlock()
+
+	if m.reconciler == nil {
+		return
+	}
+	m.reconciler.cancel()
+	<-m.reconciler.done
+	m.reconciler = nil
+}
+
+// reconcile suspends or drops, depending on the reconcile_action, every user
+// matching the reconcile_username_pattern that is older than the
+// reconcile_max_age. The root user is never touched.
+func (m *Neo4j) reconcile(ctx context.Context) error {
+	showUsersCmd := showUsersCommand{
+		Community: m.serverInfo(ctx).isCommunity(),
+	}
+	var query, params = showUsersCmd.transform()
+	records, err := m.runQueryWithRetry(ctx, query, params)
+	if err != nil {
+		return fmt.Errorf("failed to list users: %w", err)
+	}
+
+	var errs []error
+	now := time.Now()
+	for _, record := range records {
+		username, ok := record.Values[0].(string)
+		if !ok || username == m.Username {
+			continue
+		}
+		suspended, _ := record.Values[1].(bool)
+
+		createdAt, ok := m.userCreatedAt(username)
+		if !ok || now.Sub(createdAt) < m.ReconcileMaxAge {
+			continue
+		}
+
+		if err := m.reconcileUser(ctx, username, suspended, createdAt); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (m *Neo4j) reconcileUser(ctx context.Context, username string, suspended bool, createdAt time.Time) error {
+	if m.ReconcileAction == expiredUserActionDrop {
+		if err := m.dropUser(ctx, username); err != nil {
+			return fmt.Errorf("failed to drop orphaned user %q: %w", username, err)
+		}
+		m.usersLogger.Info("dropped orphaned user", "username", username, "created_at", createdAt)
+		return nil
+	}
+
+	if suspended {
+		return nil
+	}
+	alterUserStatusCmd := alterUserStatusCommand{
+		Username:  username,
+		Suspended: true,
+		IfExists:  true,
+	}
+	if err := alterUserStatusCmd.checkSupport(m.serverInfo(ctx)); err != nil {
+		return fmt.Errorf("failed to suspend orphaned user %q: %w", username, err)
+	}
+	var command, params = alterUserStatusCmd.transform()
+	if err := m.runCommandWithRetry(ctx, command, params); err != nil {
+		return fmt.Errorf("failed to suspend orphaned user %q: %w", username, err)
+	}
+	m.usersLogger.Info("suspended orphaned user", "username", username, "created_at", createdAt)
+	return nil
+}
+
+// userCreatedAt returns the creation time encoded in the username, and whether
+// the username matches the reconcile_username_pattern at all.
+func (c *neo4jConnectionProducer) userCreatedAt(username string) (time.Time, bool) {
+	match := c.reconcileUsernameRegex.FindStringSubmatch(username)
+	if match == nil {
+		return time.Time{}, false
+	}
+
+	unixTime, err := strconv.ParseInt(match[c.reconcileUsernameRegex.SubexpIndex(reconcileTimeGroup)], 10, 64)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return time.Unix(unixTime, 0), true
+}
+
+// loadReconcileConfig validates the reconciler settings and compiles the
+// username pattern. The default pattern only matches the usernames of the
+// default username template, so a custom template requires an explicit
+// pattern when the reconciler is enabled.
+func (c *neo4jConnectionProducer) loadReconcileConfig(usernameTemplate string) error {
+	if c.ReconcileInterval < 0 {
+		return fmt.Errorf("reconcile_interval must be >= 0")
+	}
+	if c.ReconcileMaxAge < 0 {
+		return fmt.Errorf("reconcile_max_age must be >= 0")
+	}
+	if c.ReconcileInterval > 0 && c.ReconcileMaxAge == 0 {
+		return fmt.Errorf("reconcile_max_age is required when reconcile_interval is set")
+	}
+
+	switch c.ReconcileAction {
+	case "":
+		c.ReconcileAction = expiredUserActionSuspend
+	case expiredUserActionSuspend, expiredUserActionDrop:
+	default:
+		return fmt.Errorf("reconcile_action must be %q or %q", expiredUserActionSuspend, expiredUserActionDrop)
+	}
+
+	if c.ReconcileUsernamePattern == "" {
+		customTemplate := usernameTemplate != "" && usernameTemplate != defaultUserNameTemplate
+		if c.ReconcileInterval > 0 && customTemplate {
+			return fmt.Errorf("reconcile_username_pattern is required when reconcile_interval is set with a custom username_template")
+		}
+		c.ReconcileUsernamePattern = defaultReconcileUsernamePattern
+	}
+	usernameRegex, err := regexp.Compile(c.ReconcileUsernamePattern)
+	if err != nil {
+		return fmt.Errorf("invalid reconcile_username_pattern: %w", err)
+	}
+	if usernameRegex.SubexpIndex(reconcileTimeGroup) < 0 {
+		return fmt.Errorf("reconcile_username_pattern must have a (?P<%s>...) group capturing the creation time", reconcileTimeGroup)
+	}
+	c.reconcileUsernameRegex = usernameRegex
+
+	return nil
+}
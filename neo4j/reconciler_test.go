@@ -0,0 +1,110 @@
+package neo4j
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/database/dbplugin/v5"
+	"github.com/hashicorp/vault/sdk/helper/template"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUserCreatedAt(t *testing.T) {
+	c := new()
+	err := c.loadConfig(map[string]interface{}{
+		"connection_url": "neo4j://localhost:7687",
+	})
+	require.NoError(t, err)
+
+	up, err := template.NewTemplate(template.Template(defaultUserNameTemplate))
+	require.NoError(t, err)
+	username, err := up.Generate(dbplugin.UsernameMetadata{DisplayName: "token", RoleName: "my.role"})
+	require.NoError(t, err)
+
+	createdAt, ok := c.userCreatedAt(username)
+	require.True(t, ok)
+	require.WithinDuration(t, time.Now(), createdAt, time.Minute)
+
+	_, ok = c.userCreatedAt("neo4j")
+	require.False(t, ok)
+}
+
+func TestLoadReconcileConfig(t *testing.T) {
+	type testCase struct {
+		config map[string]interface{}
+
+		expectErr string
+	}
+
+	tests := map[string]testCase{
+		"disabled": {
+			config: map[string]interface{}{},
+		},
+		"enabled": {
+			config: map[string]interface{}{
+				"reconcile_interval": "1h",
+				"reconcile_max_age":  "72h",
+				"reconcile_action":   "drop",
+			},
+		},
+		"custom pattern": {
+			config: map[string]interface{}{
+				"reconcile_interval":         "1h",
+				"reconcile_max_age":          "72h",
+				"reconcile_username_pattern": `^vault_(?P<unix_time>\d+)_`,
+			},
+		},
+		"missing max age": {
+			config: map[string]interface{}{
+				"reconcile_interval": "1h",
+			},
+			expectErr: "reconcile_max_age is required when reconcile_interval is set",
+		},
+		"invalid action": {
+			config: map[string]interface{}{
+				"reconcile_action": "delete",
+			},
+			expectErr: `reconcile_action must be "suspend" or "drop"`,
+		},
+		"custom template with custom pattern": {
+			config: map[string]interface{}{
+				"username_template":          "vault_{{unix_time}}_{{.RoleName}}",
+				"reconcile_interval":         "1h",
+				"reconcile_max_age":          "72h",
+				"reconcile_username_pattern": `^vault_(?P<unix_time>\d+)_`,
+			},
+		},
+		"custom template without pattern": {
+			config: map[string]interface{}{
+				"username_template":  "vault_{{unix_time}}_{{.RoleName}}",
+				"reconcile_interval": "1h",
+				"reconcile_max_age":  "72h",
+			},
+			expectErr: "reconcile_username_pattern is required when reconcile_interval is set with a custom username_template",
+		},
+		"custom template with reconciler disabled": {
+			config: map[string]interface{}{
+				"username_template": "vault_{{unix_time}}_{{.RoleName}}",
+			},
+		},
+		"pattern without time": {
+			config: map[string]interface{}{
+				"reconcile_username_pattern": `^v-`,
+			},
+			expectErr: "reconcile_username_pattern must have a (?P<unix_time>...) group capturing the creation time",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			c := new()
+			test.config["connection_url"] = "neo4j://localhost:7687"
+			err := c.loadConfig(test.config)
+			if test.expectErr != "" {
+				require.EqualError(t, err, test.expectErr)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
@@ -0,0 +1,124 @@
+package neo4j
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+)
+
+const (
+	defaultRetryMaxAttempts    = 3
+	defaultRetryMaxElapsedTime = 30 * time.Second
+
+	retryInitialBackoff = 100 * time.Millisecond
+	retryMaxBackoff     = 5 * time.Second
+)
+
+// retryPolicy retries operations failing with transient errors, using
+// exponential backoff with jitter between the attempts.
+type retryPolicy struct {
+	maxAttempts    int
+	maxElapsedTime time.Duration
+	initialBackoff time.Duration
+	maxBackoff     time.Duration
+	logger         hclog.Logger
+
+	// beforeRetry, if set, is called with the error of a failed attempt
+	// before the next one.
+	beforeRetry func(context.Context, error)
+
+	// sanitizeError, if set, removes secrets from errors before they are
+	// logged.
+	sanitizeError func(error) error
+}
+
+// run calls operation until it succeeds, fails with an error that is not
+// retryable, or the attempts, elapsed time or ctx are exhausted. The error of
+// the last attempt is returned.
+func (p retryPolicy) run(ctx context.Context, operation func(context.Context) error) error {
+	start := time.Now()
+	backoff := p.initialBackoff
+
+	for attempt := 1; ; attempt++ {
+		err := operation(ctx)
+		if err == nil || !isRetryableError(err) || attempt >= p.maxAttempts {
+			return err
+		}
+
+		wait := withJitter(backoff)
+		if p.maxElapsedTime > 0 && time.Since(start)+wait > p.maxElapsedTime {
+			return err
+		}
+
+		loggedErr := err
+		if p.sanitizeError != nil {
+			loggedErr = p.sanitizeError(err)
+		}
+		p.logger.Debug("retrying after transient error", "attempt", attempt, "backoff", wait, "error", loggedErr)
+		if p.beforeRetry != nil {
+			p.beforeRetry(ctx, err)
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+
+		backoff *= 2
+		if backoff > p.maxBackoff {
+			backoff = p.maxBackoff
+		}
+	}
+}
+
+// withJitter returns a random duration between half of and the full backoff,
+// so that concurrent callers don't retry in lock step.
+func withJitter(backoff time.Duration) time.Duration {
+	half := int64(backoff / 2)
+	if half <= 0 {
+		return backoff
+	}
+	return time.Duration(half + rand.Int63n(half+1))
+}
+
+// isRetryableError classifies errors returned by the driver. Transient and
+// connectivity errors as well as cluster leader switches are retryable, client
+// errors and cancellations are not.
+func isRetryableError(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	// The driver gives up on managed transactions once it has retried them for
+	// max_transaction_retry_time. Retrying again would multiply both budgets.
+	var executionLimit *neo4j.TransactionExecutionLimit
+	if errors.As(err, &executionLimit) {
+		return false
+	}
+
+	// Leader switches on direct connections are retried on the new leader.
+	var leaderErr *notALeaderError
+	if errors.As(err, &leaderErr) {
+		return true
+	}
+
+	var neo4jErr *neo4j.Neo4jError
+	if errors.As(err, &neo4jErr) {
+		return neo4jErr.IsRetriableTransient() || neo4jErr.IsRetriableCluster()
+	}
+
+	var connectivityErr *neo4j.ConnectivityError
+	if errors.As(err, &connectivityErr) {
+		return neo4j.IsRetryable(connectivityErr)
+	}
+
+	return errors.Is(err, io.EOF)
+}
@@ -0,0 +1,182 @@
+package neo4j
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsRetryableError(t *testing.T) {
+	tests := map[string]struct {
+		err      error
+		expected bool
+	}{
+		"nil": {
+			err: nil,
+		},
+		"transient error": {
+			err:      &neo4j.Neo4jError{Code: "Neo.TransientError.General.DatabaseUnavailable"},
+			expected: true,
+		},
+		"wrapped transient error": {
+			err:      fmt.Errorf("failed: %w", &neo4j.Neo4jError{Code: "Neo.TransientError.Transaction.DeadlockDetected"}),
+			expected: true,
+		},
+		"leader switch": {
+			err:      &neo4j.Neo4jError{Code: "Neo.ClientError.Cluster.NotALeader"},
+			expected: true,
+		},
+		"leader switch on a direct connection": {
+			err:      &notALeaderError{err: &neo4j.Neo4jError{Code: "Neo.ClientError.Cluster.NotALeader"}},
+			expected: true,
+		},
+		"client error": {
+			err: &neo4j.Neo4jError{Code: "Neo.ClientError.Statement.SyntaxError"},
+		},
+		"unauthorized": {
+			err: &neo4j.Neo4jError{Code: "Neo.ClientError.Security.Unauthorized"},
+		},
+		"connectivity error": {
+			err:      &neo4j.ConnectivityError{Inner: io.EOF},
+			expected: true,
+		},
+		"transaction execution limit": {
+			err: &neo4j.TransactionExecutionLimit{
+				Cause:  "timeout",
+				Errors: []error{&neo4j.Neo4jError{Code: "Neo.TransientError.General.DatabaseUnavailable"}},
+			},
+		},
+		"EOF": {
+			err:      io.EOF,
+			expected: true,
+		},
+		"context canceled": {
+			err: context.Canceled,
+		},
+		"other error": {
+			err: errors.New("boom"),
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			require.Equal(t, test.expected, isRetryableError(test.err))
+		})
+	}
+}
+
+func TestRetryPolicy_run(t *testing.T) {
+	transientErr := &neo4j.Neo4jError{Code: "Neo.TransientError.General.DatabaseUnavailable"}
+	clientErr := &neo4j.Neo4jError{Code: "Neo.ClientError.Statement.SyntaxError"}
+
+	policy := retryPolicy{
+		maxAttempts:    3,
+		maxElapsedTime: time.Second,
+		initialBackoff: time.Millisecond,
+		maxBackoff:     2 * time.Millisecond,
+		logger:         hclog.NewNullLogger(),
+	}
+
+	t.Run("succeeds after transient errors", func(t *testing.T) {
+		attempts := 0
+		err := policy.run(context.Background(), func(ctx context.Context) error {
+			attempts++
+			if attempts < 3 {
+				return transientErr
+			}
+			return nil
+		})
+		require.NoError(t, err)
+		require.Equal(t, 3, attempts)
+	})
+
+	t.Run("stops after max attempts", func(t *testing.T) {
+		attempts := 0
+		err := policy.run(context.Background(), func(ctx context.Context) error {
+			attempts++
+			return transientErr
+		})
+		require.ErrorIs(t, err, transientErr)
+		require.Equal(t, 3, attempts)
+	})
+
+	t.Run("does not retry client errors", func(t *testing.T) {
+		attempts := 0
+		err := policy.run(context.Background(), func(ctx context.Context) error {
+			attempts++
+			return clientErr
+		})
+		require.ErrorIs(t, err, clientErr)
+		require.Equal(t, 1, attempts)
+	})
+
+	t.Run("calls beforeRetry between attempts", func(t *testing.T) {
+		var seen []error
+		hookedPolicy := policy
+		hookedPolicy.beforeRetry = func(ctx context.Context, err error) {
+			seen = append(seen, err)
+		}
+
+		err := hookedPolicy.run(context.Background(), func(ctx context.Context) error {
+			return transientErr
+		})
+		require.ErrorIs(t, err, transientErr)
+		require.Equal(t, []error{transientErr, transientErr}, seen)
+	})
+
+	t.Run("sanitizes logged errors", func(t *testing.T) {
+		var logs bytes.Buffer
+		sanitizedPolicy := policy
+		sanitizedPolicy.logger = hclog.New(&hclog.LoggerOptions{Output: &logs, Level: hclog.Debug})
+		sanitizedPolicy.sanitizeError = func(err error) error {
+			return errors.New(strings.ReplaceAll(err.Error(), "secret", "[password]"))
+		}
+
+		secretErr := &neo4j.Neo4jError{Code: "Neo.TransientError.General.DatabaseUnavailable", Msg: "password secret"}
+		err := sanitizedPolicy.run(context.Background(), func(ctx context.Context) error {
+			return secretErr
+		})
+		require.ErrorIs(t, err, secretErr)
+		require.Contains(t, logs.String(), "[password]")
+		require.NotContains(t, logs.String(), "secret")
+	})
+
+	t.Run("stops when the context is done", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		slowPolicy := policy
+		slowPolicy.initialBackoff = time.Minute
+		slowPolicy.maxElapsedTime = time.Hour
+
+		attempts := 0
+		err := slowPolicy.run(ctx, func(ctx context.Context) error {
+			attempts++
+			return transientErr
+		})
+		require.ErrorIs(t, err, transientErr)
+		require.Equal(t, 1, attempts)
+	})
+
+	t.Run("stops when the elapsed time is exhausted", func(t *testing.T) {
+		slowPolicy := policy
+		slowPolicy.initialBackoff = time.Minute
+
+		attempts := 0
+		err := slowPolicy.run(context.Background(), func(ctx context.Context) error {
+			attempts++
+			return transientErr
+		})
+		require.ErrorIs(t, err, transientErr)
+		require.Equal(t, 1, attempts)
+	})
+}
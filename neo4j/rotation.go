@@ -0,0 +1,125 @@
+package neo4j
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	dbplugin "github.com/hashicorp/vault/sdk/database/dbplugin/v5"
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+)
+
+// isRootRotation reports whether the password of the admin user itself is
+// changed, which the connection has to follow. Tokens and tickets are not
+// affected by password changes.
+func (c *neo4jConnectionProducer) isRootRotation(username string) bool {
+	return username == c.Username && c.AuthMethod == authMethodBasic
+}
+
+// isSamePasswordError reports whether neo4j refused to set a password because
+// the user already has it.
+func isSamePasswordError(err error) bool {
+	return strings.Contains(err.Error(), "Old password and new password cannot be the same")
+}
+
+// rotateRootPassword changes the password of the admin user and verifies it
+// with a new driver before the connection switches over to it. If the new
+// password can't be verified, the old one is restored, so that Vault never
+// ends up with an admin user it can't log in as.
+func (m *Neo4j) rotateRootPassword(ctx context.Context, password string, rotationStatements dbplugin.Statements) error {
+	m.RLock()
+	oldPassword := m.Password
+	m.RUnlock()
+
+	if err := m.changeUserPassword(ctx, m.Username, password, rotationStatements); err != nil {
+		return err
+	}
+
+	// A direct connection may point at a follower, which learns about the new
+	// password only after the leader the password was changed on.
+	verifyURL, onLeader := m.ConnectionURL, false
+	if m.isDirect() {
+		if leaderURL := m.leaderURL(m.clientOptions.DatabaseName); leaderURL != "" {
+			verifyURL, onLeader = leaderURL, true
+		}
+	}
+
+	client, err := m.verifyRootPassword(ctx, verifyURL, password)
+	if err != nil {
+		m.usersLogger.Error("failed to verify the new root password, restoring the old one", "username", m.Username, "error", m.sanitizeError(err, nil))
+
+		restoreUserCmd := updateUserCommand{
+			Username: m.Username,
+			Password: oldPassword,
+		}
+		var command, params = restoreUserCmd.transform()
+		// Rotation statements may not have changed the password at all.
+		if restoreErr := m.runCommandWithRetry(ctx, command, params); restoreErr != nil && !isSamePasswordError(restoreErr) {
+			return fmt.Errorf("failed to verify the new root password: %w; failed to restore the old one: %w", err, restoreErr)
+		}
+		return fmt.Errorf("failed to verify the new root password, restored the old one: %w", err)
+	}
+
+	m.switchRootPassword(ctx, password, client, verifyURL, onLeader)
+	m.usersLogger.Info("rotated root password", "username", m.Username)
+	return nil
+}
+
+// verifyRootPassword connects to the server at the given URL with the new
+// password of the admin user and checks that it authenticates as the admin
+// user. The query waits for the bookmarks of the admin sessions, i.e. for the
+// password change. The driver is returned for the connection to switch to.
+func (m *Neo4j) verifyRootPassword(ctx context.Context, url, password string) (neo4j.DriverWithContext, error) {
+	client, err := neo4j.NewDriverWithContext(url, neo4j.BasicAuth(m.Username, password, ""), m.configureDriver)
+	if err != nil {
+		return nil, err
+	}
+
+	queryCtx, cancel := m.withSocketTimeout(ctx)
+	defer cancel()
+
+	showCurrentUserCmd := showCurrentUserCommand{}
+	var query, params = showCurrentUserCmd.transform()
+	result, err := neo4j.ExecuteQuery(queryCtx, client, query, params,
+		neo4j.EagerResultTransformer,
+		neo4j.ExecuteQueryWithDatabase(m.clientOptions.DatabaseName),
+		neo4j.ExecuteQueryWithBookmarkManager(m.clientOptions.BookmarkManager))
+	if err == nil && len(result.Records) == 0 {
+		err = fmt.Errorf("no current user")
+	}
+	if err == nil {
+		if user, _ := result.Records[0].Values[0].(string); user != m.Username {
+			err = fmt.Errorf("authenticated as %q instead of %q", user, m.Username)
+		}
+	}
+	if err != nil {
+		_ = client.Close(ctx)
+		return nil, err
+	}
+	return client, nil
+}
+
+// switchRootPassword swaps the password and the drivers of the connection in
+// one step. The verified driver becomes the driver of the connection, or of
+// the leader if it is connected to one. All other drivers still log in with
+// the old password and are closed.
+func (m *Neo4j) switchRootPassword(ctx context.Context, password string, client neo4j.DriverWithContext, url string, onLeader bool) {
+	m.Lock()
+	previous := m.client
+	m.closeLeaders()
+	m.Password = password
+	if onLeader {
+		m.client = nil
+		m.leaders = map[string]neo4j.DriverWithContext{m.clientOptions.DatabaseName: client}
+		m.leaderURLs = map[string]string{m.clientOptions.DatabaseName: url}
+	} else {
+		m.client = client
+	}
+	m.Unlock()
+
+	m.resetAuthCircuit()
+	if previous != nil {
+		// Ignore error on purpose since the driver is replaced anyway
+		_ = previous.Close(ctx)
+	}
+}
@@ -0,0 +1,27 @@
+package neo4j
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsRootRotation(t *testing.T) {
+	c := new()
+	c.Username = "neo4j"
+	c.AuthMethod = authMethodBasic
+
+	require.True(t, c.isRootRotation("neo4j"))
+	require.False(t, c.isRootRotation("v-token-role-abc"))
+
+	c.AuthMethod = authMethodBearer
+	require.False(t, c.isRootRotation("neo4j"))
+}
+
+func TestIsSamePasswordError(t *testing.T) {
+	err := &neo4j.Neo4jError{Code: "Neo.ClientError.General.InvalidArguments", Msg: "Failed to alter the specified user 'neo4j': Old password and new password cannot be the same."}
+	require.True(t, isSamePasswordError(err))
+	require.False(t, isSamePasswordError(errors.New("boom")))
+}
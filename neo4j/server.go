@@ -0,0 +1,140 @@
+package neo4j
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+)
+
+const editionCommunity = "community"
+
+// serverInfo is the version and edition of the neo4j server. The zero value
+// stands for an unknown server, which is assumed to support everything.
+type serverInfo struct {
+	Version string
+	Edition string
+
+	major int
+	minor int
+}
+
+// parseServerInfo parses the version as returned by dbms.components(), e.g.
+// 4.4.30, 5.19.0 or 2025.01.0.
+func parseServerInfo(version, edition string) (serverInfo, error) {
+	parts := strings.SplitN(version, ".", 3)
+	if len(parts) < 2 {
+		return serverInfo{}, fmt.Errorf("unexpected server version %q", version)
+	}
+
+	major, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return serverInfo{}, fmt.Errorf("unexpected server version %q", version)
+	}
+	minor, err := strconv.Atoi(strings.TrimRightFunc(parts[1], func(r rune) bool { return r < '0' || r > '9' }))
+	if err != nil {
+		return serverInfo{}, fmt.Errorf("unexpected server version %q", version)
+	}
+
+	return serverInfo{
+		Version: version,
+		Edition: strings.ToLower(edition),
+		major:   major,
+		minor:   minor,
+	}, nil
+}
+
+func (s serverInfo) known() bool {
+	return s.Version != ""
+}
+
+// isCommunity reports whether the server is a Community Edition, which has no
+// roles, privileges, user status or home databases.
+func (s serverInfo) isCommunity() bool {
+	return s.Edition == editionCommunity
+}
+
+// atLeast reports whether the server is at least of the given version.
+func (s serverInfo) atLeast(major, minor int) bool {
+	if !s.known() {
+		return true
+	}
+	return s.major > major || s.major == major && s.minor >= minor
+}
+
+// requireEnterprise returns an error if the server is a Community Edition.
+func (s serverInfo) requireEnterprise(feature string) error {
+	if s.isCommunity() {
+		return fmt.Errorf("%s: not supported on Community Edition", feature)
+	}
+	return nil
+}
+
+// requireVersion returns an error if the server is older than the given
+// version.
+func (s serverInfo) requireVersion(feature string, major, minor int) error {
+	if !s.atLeast(major, minor) {
+		return fmt.Errorf("%s: requires neo4j %d.%d or later, the server runs %s", feature, major, minor, s.Version)
+	}
+	return nil
+}
+
+// detectServer queries the version and edition of the server and caches them.
+// The caller must hold the lock.
+func (c *neo4jConnectionProducer) detectServer(ctx context.Context, client neo4j.DriverWithContext) error {
+	queryCtx, cancel := c.withSocketTimeout(ctx)
+	defer cancel()
+
+	result, err := neo4j.ExecuteQuery(queryCtx, client,
+		"CALL dbms.components() YIELD name, versions, edition WHERE name = 'Neo4j Kernel' RETURN versions[0], edition",
+		nil,
+		neo4j.EagerResultTransformer,
+		neo4j.ExecuteQueryWithDatabase(c.clientOptions.DatabaseName),
+		neo4j.ExecuteQueryWithReadersRouting())
+	if err != nil {
+		return fmt.Errorf("failed to detect the server version: %w", err)
+	}
+	if len(result.Records) == 0 {
+		return fmt.Errorf("failed to detect the server version: no kernel component")
+	}
+
+	version, _ := result.Records[0].Values[0].(string)
+	edition, _ := result.Records[0].Values[1].(string)
+	server, err := parseServerInfo(version, edition)
+	if err != nil {
+		return err
+	}
+
+	c.logger.Debug("detected server", "version", server.Version, "edition", server.Edition)
+	c.server = &server
+	return nil
+}
+
+// serverInfo returns the version and edition of the server, connecting to it
+// first if they haven't been detected yet. Unknown servers are returned as the
+// zero value.
+func (c *neo4jConnectionProducer) serverInfo(ctx context.Context) serverInfo {
+	c.RLock()
+	server := c.server
+	c.RUnlock()
+
+	if server == nil {
+		// The server is detected when the driver is created.
+		session, err := c.Connection(ctx)
+		if err != nil {
+			return serverInfo{}
+		}
+		_ = session.Close(ctx)
+
+		c.RLock()
+		server = c.server
+		c.RUnlock()
+	}
+
+	if server == nil {
+		return serverInfo{}
+	}
+	return *server
+}
@@ -0,0 +1,51 @@
+package neo4j
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseServerInfo(t *testing.T) {
+	tests := map[string]struct {
+		version string
+
+		expectMajor int
+		expectMinor int
+		expectErr   bool
+	}{
+		"4.x":     {version: "4.4.30", expectMajor: 4, expectMinor: 4},
+		"5.x":     {version: "5.19.0", expectMajor: 5, expectMinor: 19},
+		"aura":    {version: "5.24-aura", expectMajor: 5, expectMinor: 24},
+		"calver":  {version: "2025.01.0", expectMajor: 2025, expectMinor: 1},
+		"invalid": {version: "dev", expectErr: true},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			server, err := parseServerInfo(test.version, "Enterprise")
+			if test.expectErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, test.expectMajor, server.major)
+			require.Equal(t, test.expectMinor, server.minor)
+			require.Equal(t, "enterprise", server.Edition)
+		})
+	}
+}
+
+func TestServerInfo_atLeast(t *testing.T) {
+	server, err := parseServerInfo("5.19.0", "enterprise")
+	require.NoError(t, err)
+
+	require.True(t, server.atLeast(4, 3))
+	require.True(t, server.atLeast(5, 19))
+	require.False(t, server.atLeast(5, 24))
+	require.True(t, serverInfo{}.atLeast(5, 24))
+
+	calver, err := parseServerInfo("2025.01.0", "enterprise")
+	require.NoError(t, err)
+	require.True(t, calver.atLeast(5, 24))
+}
@@ -0,0 +1,403 @@
+package neo4j
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/hashicorp/go-secure-stdlib/strutil"
+)
+
+const (
+	// creationStatementVersion is the only version of the creation statement
+	// schema currently understood by the plugin.
+	creationStatementVersion = 1
+
+	userStatusActive    = "active"
+	userStatusSuspended = "suspended"
+
+	// statusKeywordActivate and statusKeywordSuspend are the statements that
+	// change the status of a user on update.
+	statusKeywordActivate = "ACTIVATE"
+	statusKeywordSuspend  = "SUSPEND"
+
+	privilegeActionAccess   = "access"
+	privilegeActionTraverse = "traverse"
+	privilegeActionRead     = "read"
+	privilegeActionMatch    = "match"
+	privilegeActionWrite    = "write"
+	privilegeActionCreate   = "create"
+	privilegeActionDelete   = "delete"
+)
+
+// nativeAuthProvider is the name of the native auth provider of neo4j, which
+// is configured through native_auth rather than auth_providers.
+const nativeAuthProvider = "native"
+
+// placeholderRegex matches the {{username}}, {{name}}, {{password}} and
+// {{expiration}} placeholders of cypher statements.
+var placeholderRegex = regexp.MustCompile(`\{\{\s*(username|name|password|expiration)\s*\}\}`)
+
+// createUserStatementRegex matches cypher statements that create a user.
+var createUserStatementRegex = regexp.MustCompile(`(?i)^\s*CREATE\s+(OR\s+REPLACE\s+)?USER\b`)
+
+// databaseNameRegex matches the names neo4j accepts for databases: 3 to 63
+// ASCII alphanumerics, dots and dashes, starting with a letter.
+var databaseNameRegex = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9.\-]{2,62}$`)
+
+// creationStatement is the neo4j native creation statement, e.g.:
+//
+//	{
+//	  "version": 1,
+//	  "roles": [ "reader" ],
+//	  "home_database": "sales",
+//	  "copy_role_from": "analyst_template",
+//	  "auth_providers": [ { "provider": "oidc-okta", "id": "{{display_name}}" } ],
+//	  "native_auth": false,
+//	  "privileges": [
+//	    { "action": "match", "properties": [ "*" ], "graph": "sales", "nodes": [ "Customer" ] },
+//	    { "action": "read", "deny": true, "properties": [ "ssn" ], "graph": "sales" }
+//	  ]
+//	}
+type creationStatement struct {
+	Version      int              `json:"version"`
+	Roles        []string         `json:"roles"`
+	HomeDatabase string           `json:"home_database"`
+	Status       string           `json:"status"`
+	CopyRoleFrom string           `json:"copy_role_from"`
+	Privileges   []graphPrivilege `json:"privileges"`
+
+	AuthProviders []authProvider `json:"auth_providers"`
+	NativeAuth    *bool          `json:"native_auth"`
+
+	// legacy is set if the statement was converted from the deprecated role
+	// document.
+	legacy bool
+}
+
+// graphPrivilege is a single privilege on a graph, granted (or denied) to the
+// user through the creation statement.
+type graphPrivilege struct {
+	Action        string   `json:"action"`
+	Deny          bool     `json:"deny"`
+	Graph         string   `json:"graph"`
+	Properties    []string `json:"properties"`
+	Nodes         []string `json:"nodes"`
+	Relationships []string `json:"relationships"`
+}
+
+// authProvider binds the user to the ID of an external auth provider, e.g. the
+// subject of an OIDC provider. The ID may contain the {{username}} and
+// {{display_name}} placeholders.
+type authProvider struct {
+	Provider string `json:"provider"`
+	ID       string `json:"id"`
+}
+
+// parseCreationStatement parses a JSON creation statement. Statements carrying
+// a "version" are decoded strictly against the native schema, anything else is
+// treated as the legacy role document and converted.
+func parseCreationStatement(raw string) (creationStatement, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(raw), &fields); err != nil {
+		return creationStatement{}, fmt.Errorf("invalid creation statement: %w", err)
+	}
+
+	if _, ok := fields["version"]; !ok {
+		return parseLegacyCreationStatement(raw)
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader([]byte(raw)))
+	decoder.DisallowUnknownFields()
+
+	var cs creationStatement
+	if err := decoder.Decode(&cs); err != nil {
+		return creationStatement{}, fmt.Errorf("invalid creation statement: %w", describeDecodeError(err))
+	}
+	if err := cs.validate(); err != nil {
+		return creationStatement{}, fmt.Errorf("invalid creation statement: %w", err)
+	}
+	return cs, nil
+}
+
+// parseLegacyCreationStatement converts the deprecated role document of the
+// form { "db": "admin", "roles": [ { "role": "reader" } ] } into a creation
+// statement.
+func parseLegacyCreationStatement(raw string) (creationStatement, error) {
+	var legacy neo4jStatement
+	if err := json.Unmarshal([]byte(raw), &legacy); err != nil {
+		return creationStatement{}, fmt.Errorf("invalid creation statement: %w", describeDecodeError(err))
+	}
+
+	if len(legacy.Roles) == 0 {
+		return creationStatement{}, fmt.Errorf("roles array is required in creation statement")
+	}
+
+	cs := creationStatement{
+		Version: creationStatementVersion,
+		Roles:   legacy.Roles.toRoleNames(),
+		legacy:  true,
+	}
+	if err := cs.validate(); err != nil {
+		return creationStatement{}, fmt.Errorf("invalid creation statement: %w", err)
+	}
+	return cs, nil
+}
+
+func (cs creationStatement) validate() error {
+	if cs.Version != creationStatementVersion {
+		return fmt.Errorf("version: unsupported version %d, expected %d", cs.Version, creationStatementVersion)
+	}
+
+	for i, role := range cs.Roles {
+		if strings.TrimSpace(role) == "" {
+			return fmt.Errorf("roles[%d]: role name must not be empty", i)
+		}
+	}
+
+	if cs.HomeDatabase != "" && !databaseNameRegex.MatchString(cs.HomeDatabase) {
+		return fmt.Errorf("home_database: %q is not a valid database name", cs.HomeDatabase)
+	}
+
+	// Vault only rotates the passwords of static roles, so nothing could ever
+	// activate a dynamic user created suspended.
+	switch strings.ToLower(cs.Status) {
+	case "", userStatusActive:
+	case userStatusSuspended:
+		return fmt.Errorf("status: dynamic users can't be activated through Vault, so they can't be created %q; use a static role whose rotation statements suspend and activate the user instead", userStatusSuspended)
+	default:
+		return fmt.Errorf("status: must be %q, got %q", userStatusActive, cs.Status)
+	}
+
+	if cs.CopyRoleFrom != "" && strings.TrimSpace(cs.CopyRoleFrom) == "" {
+		return fmt.Errorf("copy_role_from: role name must not be empty")
+	}
+
+	for i, privilege := range cs.Privileges {
+		if err := privilege.validate(); err != nil {
+			return fmt.Errorf("privileges[%d].%w", i, err)
+		}
+	}
+
+	providers := make(map[string]bool, len(cs.AuthProviders))
+	for i, provider := range cs.AuthProviders {
+		switch {
+		case strings.TrimSpace(provider.Provider) == "":
+			return fmt.Errorf("auth_providers[%d].provider: must not be empty", i)
+		case strings.EqualFold(provider.Provider, nativeAuthProvider):
+			return fmt.Errorf("auth_providers[%d].provider: use native_auth to configure the native provider", i)
+		case providers[provider.Provider]:
+			return fmt.Errorf("auth_providers[%d].provider: duplicate provider %q", i, provider.Provider)
+		case strings.TrimSpace(provider.ID) == "":
+			return fmt.Errorf("auth_providers[%d].id: must not be empty", i)
+		}
+		providers[provider.Provider] = true
+	}
+
+	if !cs.nativeAuth() && len(cs.AuthProviders) == 0 {
+		return fmt.Errorf("native_auth: can only be disabled along with auth_providers")
+	}
+
+	return nil
+}
+
+// nativeAuth reports whether the user can authenticate with its password,
+// which is the default.
+func (cs creationStatement) nativeAuth() bool {
+	return cs.NativeAuth == nil || *cs.NativeAuth
+}
+
+// resolveAuthProviders returns the auth providers with the placeholders of
+// their IDs replaced.
+func (cs creationStatement) resolveAuthProviders(username, displayName string) []authProvider {
+	if len(cs.AuthProviders) == 0 {
+		return nil
+	}
+
+	replacer := strings.NewReplacer("{{username}}", username, "{{display_name}}", displayName)
+	providers := make([]authProvider, 0, len(cs.AuthProviders))
+	for _, provider := range cs.AuthProviders {
+		providers = append(providers, authProvider{
+			Provider: provider.Provider,
+			ID:       replacer.Replace(provider.ID),
+		})
+	}
+	return providers
+}
+
+func (p graphPrivilege) validate() error {
+	if p.Graph == "" {
+		return fmt.Errorf("graph: must not be empty")
+	}
+	if p.Graph != "*" && !databaseNameRegex.MatchString(p.Graph) {
+		return fmt.Errorf("graph: %q is not a valid graph name", p.Graph)
+	}
+
+	var allowsProperties, requiresProperties, allowsElements bool
+	switch strings.ToLower(p.Action) {
+	case privilegeActionAccess, privilegeActionWrite:
+	case privilegeActionTraverse, privilegeActionCreate, privilegeActionDelete:
+		allowsElements = true
+	case privilegeActionRead, privilegeActionMatch:
+		allowsProperties, requiresProperties, allowsElements = true, true, true
+	case "":
+		return fmt.Errorf("action: must not be empty")
+	default:
+		return fmt.Errorf("action: unsupported action %q", p.Action)
+	}
+
+	if len(p.Properties) > 0 && !allowsProperties {
+		return fmt.Errorf("properties: not allowed for action %q", p.Action)
+	}
+	if len(p.Properties) == 0 && requiresProperties {
+		return fmt.Errorf("properties: required for action %q", p.Action)
+	}
+	if err := validateNames("properties", p.Properties); err != nil {
+		return err
+	}
+
+	if (len(p.Nodes) > 0 || len(p.Relationships) > 0) && !allowsElements {
+		return fmt.Errorf("nodes: graph elements are not allowed for action %q", p.Action)
+	}
+	if len(p.Nodes) > 0 && len(p.Relationships) > 0 {
+		return fmt.Errorf("relationships: cannot be combined with nodes")
+	}
+	if err := validateNames("nodes", p.Nodes); err != nil {
+		return err
+	}
+	return validateNames("relationships", p.Relationships)
+}
+
+func validateNames(field string, names []string) error {
+	for i, name := range names {
+		if strings.TrimSpace(name) == "" {
+			return fmt.Errorf("%s[%d]: must not be empty", field, i)
+		}
+	}
+	return nil
+}
+
+// describeDecodeError rewrites JSON decoding errors so they point at the
+// offending field of the creation statement.
+func describeDecodeError(err error) error {
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) && typeErr.Field != "" {
+		return fmt.Errorf("%s: expected %s, got %s", typeErr.Field, typeErr.Type, typeErr.Value)
+	}
+	return errors.New(strings.TrimPrefix(err.Error(), "json: "))
+}
+
+// isJSONStatement reports whether a statement is a JSON creation statement
+// rather than cypher.
+func isJSONStatement(statement string) bool {
+	return strings.HasPrefix(strings.TrimSpace(statement), "{")
+}
+
+// parseCypherStatements splits the given commands into individual cypher
+// statements and replaces their placeholders with query parameters, so that
+// e.g. CREATE USER {{username}} SET PASSWORD '{{password}}' becomes
+// CREATE USER $username SET PASSWORD $password. Values are always bound as
+// parameters and never interpolated into the query text.
+func parseCypherStatements(commands []string) ([]string, error) {
+	var statements []string
+	for _, command := range commands {
+		for _, statement := range strutil.ParseArbitraryStringSlice(command, ";") {
+			statement = strings.TrimSpace(statement)
+			if statement == "" {
+				continue
+			}
+
+			parameterized, err := parameterizeStatement(statement)
+			if err != nil {
+				return nil, err
+			}
+			statements = append(statements, parameterized)
+		}
+	}
+	return statements, nil
+}
+
+// parameterizeStatement replaces the placeholders of a statement with query
+// parameters. A placeholder may stand on its own or make up a whole string
+// literal, whose quotes are removed along with it. Placeholders inside larger
+// string literals are rejected, as parameters are not substituted in strings.
+func parameterizeStatement(statement string) (string, error) {
+	literals := stringLiterals(statement)
+
+	var parameterized strings.Builder
+	last := 0
+	for _, match := range placeholderRegex.FindAllStringSubmatchIndex(statement, -1) {
+		start, end := match[0], match[1]
+		name := statement[match[2]:match[3]]
+
+		for _, literal := range literals {
+			if literal[0] >= start || literal[1] < end {
+				continue
+			}
+			if literal[0] != start-1 || literal[1] != end || end == len(statement) {
+				return "", fmt.Errorf("invalid statement %q: placeholder {{%s}} cannot be part of a larger string", statement, name)
+			}
+			start, end = literal[0], literal[1]+1
+		}
+
+		if name == "name" {
+			name = "username"
+		}
+		parameterized.WriteString(statement[last:start])
+		parameterized.WriteString("$" + name)
+		last = end
+	}
+	parameterized.WriteString(statement[last:])
+	return parameterized.String(), nil
+}
+
+// stringLiterals returns the positions of the opening and closing quotes of
+// the string literals of a statement. An unterminated literal is closed at the
+// end of the statement, past its last character.
+func stringLiterals(statement string) [][2]int {
+	var literals [][2]int
+	var quote byte
+	open := 0
+	for i := 0; i < len(statement); i++ {
+		c := statement[i]
+		switch {
+		case quote == 0 && (c == '\'' || c == '"'):
+			quote, open = c, i
+		case quote != 0 && c == '\\':
+			i++
+		case quote != 0 && c == quote:
+			literals = append(literals, [2]int{open, i})
+			quote = 0
+		}
+	}
+	if quote != 0 {
+		literals = append(literals, [2]int{open, len(statement)})
+	}
+	return literals
+}
+
+// creatingStatementIndex returns the index of the statement that creates the
+// user, which is assumed to be the first one if none of them is a CREATE USER.
+func creatingStatementIndex(statements []string) int {
+	for i, statement := range statements {
+		if createUserStatementRegex.MatchString(statement) {
+			return i
+		}
+	}
+	return 0
+}
+
+// parseStatusKeyword reports whether the statement is one of the status
+// keywords, and if so whether it suspends the user.
+func parseStatusKeyword(statement string) (suspended bool, ok bool) {
+	switch strings.ToUpper(strings.TrimSpace(statement)) {
+	case statusKeywordActivate:
+		return false, true
+	case statusKeywordSuspend:
+		return true, true
+	}
+	return false, false
+}
@@ -0,0 +1,316 @@
+package neo4j
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseCreationStatement(t *testing.T) {
+	type testCase struct {
+		statement string
+
+		expected    creationStatement
+		expectedErr string
+	}
+
+	nativeAuthDisabled := false
+
+	tests := map[string]testCase{
+		"version 1": {
+			statement: `{ "version": 1, "roles": [ "reader", "editor" ], "home_database": "sales", "status": "ACTIVE" }`,
+
+			expected: creationStatement{
+				Version:      1,
+				Roles:        []string{"reader", "editor"},
+				HomeDatabase: "sales",
+				Status:       "ACTIVE",
+			},
+		},
+		"version 1 with privileges": {
+			statement: `{ "version": 1, "privileges": [
+				{ "action": "match", "properties": [ "*" ], "graph": "sales", "nodes": [ "Customer" ] },
+				{ "action": "write", "graph": "sales" },
+				{ "action": "read", "deny": true, "properties": [ "ssn" ], "graph": "*" }
+			] }`,
+
+			expected: creationStatement{
+				Version: 1,
+				Privileges: []graphPrivilege{
+					{Action: "match", Properties: []string{"*"}, Graph: "sales", Nodes: []string{"Customer"}},
+					{Action: "write", Graph: "sales"},
+					{Action: "read", Deny: true, Properties: []string{"ssn"}, Graph: "*"},
+				},
+			},
+		},
+		"version 1 with template role": {
+			statement: `{ "version": 1, "copy_role_from": "analyst_template" }`,
+
+			expected: creationStatement{
+				Version:      1,
+				CopyRoleFrom: "analyst_template",
+			},
+		},
+		"version 1 with auth providers": {
+			statement: `{ "version": 1, "auth_providers": [ { "provider": "oidc-okta", "id": "{{display_name}}" } ], "native_auth": false }`,
+
+			expected: creationStatement{
+				Version:       1,
+				AuthProviders: []authProvider{{Provider: "oidc-okta", ID: "{{display_name}}"}},
+				NativeAuth:    &nativeAuthDisabled,
+			},
+		},
+		"native auth disabled without auth providers": {
+			statement: `{ "version": 1, "native_auth": false }`,
+
+			expectedErr: "native_auth: can only be disabled along with auth_providers",
+		},
+		"native auth provider": {
+			statement: `{ "version": 1, "auth_providers": [ { "provider": "native", "id": "alice" } ] }`,
+
+			expectedErr: "auth_providers[0].provider: use native_auth to configure the native provider",
+		},
+		"auth provider without id": {
+			statement: `{ "version": 1, "auth_providers": [ { "provider": "oidc-okta" } ] }`,
+
+			expectedErr: "auth_providers[0].id: must not be empty",
+		},
+		"empty template role": {
+			statement: `{ "version": 1, "copy_role_from": " " }`,
+
+			expectedErr: "copy_role_from: role name must not be empty",
+		},
+		"legacy role document": {
+			statement: `{ "db": "admin", "roles": [ { "role": "reader" }, { "role": "editor", "db": "test" } ] }`,
+
+			expected: creationStatement{
+				Version: 1,
+				Roles:   []string{"reader", "editor"},
+				legacy:  true,
+			},
+		},
+		"legacy role document without roles": {
+			statement: `{ "db": "admin" }`,
+
+			expectedErr: "roles array is required in creation statement",
+		},
+		"not json": {
+			statement: `CREATE USER foo`,
+
+			expectedErr: "invalid creation statement",
+		},
+		"unsupported version": {
+			statement: `{ "version": 2, "roles": [ "reader" ] }`,
+
+			expectedErr: "version: unsupported version 2, expected 1",
+		},
+		"unknown field": {
+			statement: `{ "version": 1, "rolez": [ "reader" ] }`,
+
+			expectedErr: `unknown field "rolez"`,
+		},
+		"wrong type": {
+			statement: `{ "version": 1, "roles": "reader" }`,
+
+			expectedErr: "roles: expected []string, got string",
+		},
+		"empty role": {
+			statement: `{ "version": 1, "roles": [ "reader", " " ] }`,
+
+			expectedErr: "roles[1]: role name must not be empty",
+		},
+		"invalid home database": {
+			statement: `{ "version": 1, "home_database": "a" }`,
+
+			expectedErr: "home_database:",
+		},
+		"invalid status": {
+			statement: `{ "version": 1, "status": "disabled" }`,
+
+			expectedErr: "status:",
+		},
+		"suspended status": {
+			statement: `{ "version": 1, "status": "suspended" }`,
+
+			expectedErr: "status: dynamic users can't be activated through Vault",
+		},
+		"privilege without graph": {
+			statement: `{ "version": 1, "privileges": [ { "action": "write" } ] }`,
+
+			expectedErr: "privileges[0].graph: must not be empty",
+		},
+		"privilege with unsupported action": {
+			statement: `{ "version": 1, "privileges": [ { "action": "write", "graph": "sales" }, { "action": "remove", "graph": "sales" } ] }`,
+
+			expectedErr: `privileges[1].action: unsupported action "remove"`,
+		},
+		"read privilege without properties": {
+			statement: `{ "version": 1, "privileges": [ { "action": "read", "graph": "sales" } ] }`,
+
+			expectedErr: `privileges[0].properties: required for action "read"`,
+		},
+		"write privilege with nodes": {
+			statement: `{ "version": 1, "privileges": [ { "action": "write", "graph": "sales", "nodes": [ "Customer" ] } ] }`,
+
+			expectedErr: `privileges[0].nodes: graph elements are not allowed for action "write"`,
+		},
+		"privilege with nodes and relationships": {
+			statement: `{ "version": 1, "privileges": [ { "action": "traverse", "graph": "sales", "nodes": [ "A" ], "relationships": [ "B" ] } ] }`,
+
+			expectedErr: "privileges[0].relationships: cannot be combined with nodes",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			actual, err := parseCreationStatement(test.statement)
+			if test.expectedErr != "" {
+				require.ErrorContains(t, err, test.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, test.expected, actual)
+		})
+	}
+}
+
+func TestParseCypherStatements(t *testing.T) {
+	type testCase struct {
+		commands []string
+
+		expected    []string
+		expectedErr string
+	}
+
+	tests := map[string]testCase{
+		"quoted and unquoted placeholders": {
+			commands: []string{
+				`CREATE USER {{username}} SET PASSWORD '{{password}}' CHANGE NOT REQUIRED`,
+				`GRANT ROLE reader TO {{ name }}`,
+			},
+
+			expected: []string{
+				`CREATE USER $username SET PASSWORD $password CHANGE NOT REQUIRED`,
+				`GRANT ROLE reader TO $username`,
+			},
+		},
+		"multiple statements in one command": {
+			commands: []string{
+				`CREATE USER {{username}} SET PASSWORD "{{password}}"; GRANT ROLE reader TO {{username}};`,
+			},
+
+			expected: []string{
+				`CREATE USER $username SET PASSWORD $password`,
+				`GRANT ROLE reader TO $username`,
+			},
+		},
+		"expiration": {
+			commands: []string{
+				`MERGE (u:User {name: {{username}}}) SET u.expires = datetime('{{expiration}}')`,
+			},
+
+			expected: []string{
+				`MERGE (u:User {name: $username}) SET u.expires = datetime($expiration)`,
+			},
+		},
+		"placeholder inside a string": {
+			commands: []string{
+				`CREATE USER {{username}} SET PASSWORD 'x{{password}}'`,
+			},
+
+			expectedErr: "placeholder {{password}} cannot be part of a larger string",
+		},
+		"placeholder in the middle of a string": {
+			commands: []string{
+				`CREATE USER {{username}} SET PASSWORD 'x{{password}}y'`,
+			},
+
+			expectedErr: "placeholder {{password}} cannot be part of a larger string",
+		},
+		"placeholder after an escaped quote": {
+			commands: []string{
+				`CREATE USER {{username}} SET PASSWORD "it\"{{password}}"`,
+			},
+
+			expectedErr: "placeholder {{password}} cannot be part of a larger string",
+		},
+		"placeholder in an unterminated string": {
+			commands: []string{
+				`CREATE USER {{username}} SET PASSWORD '{{password}}`,
+			},
+
+			expectedErr: "placeholder {{password}} cannot be part of a larger string",
+		},
+		"placeholders next to strings": {
+			commands: []string{
+				`MERGE (u:User {name: {{username}}, note: 'x'}) SET u.expires = '{{expiration}}', u.label = 'a' + {{name}}`,
+			},
+
+			expected: []string{
+				`MERGE (u:User {name: $username, note: 'x'}) SET u.expires = $expiration, u.label = 'a' + $username`,
+			},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			actual, err := parseCypherStatements(test.commands)
+			if test.expectedErr != "" {
+				require.ErrorContains(t, err, test.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, test.expected, actual)
+		})
+	}
+}
+
+func TestCreatingStatementIndex(t *testing.T) {
+	require.Equal(t, 1, creatingStatementIndex([]string{
+		"CREATE ROLE vault_role IF NOT EXISTS",
+		"create user $username SET PASSWORD $password",
+		"GRANT ROLE vault_role TO $username",
+	}))
+	require.Equal(t, 0, creatingStatementIndex([]string{
+		"CREATE OR REPLACE USER $username SET PASSWORD $password",
+	}))
+	require.Equal(t, 0, creatingStatementIndex([]string{
+		"CALL custom.createUser($username, $password)",
+		"GRANT ROLE reader TO $username",
+	}))
+}
+
+func TestParseStatusKeyword(t *testing.T) {
+	tests := map[string]struct {
+		statement       string
+		expectSuspended bool
+		expectOk        bool
+	}{
+		"activate":  {statement: "ACTIVATE", expectOk: true},
+		"suspend":   {statement: " suspend ", expectSuspended: true, expectOk: true},
+		"statement": {statement: "ALTER USER $username SET STATUS SUSPENDED"},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			suspended, ok := parseStatusKeyword(test.statement)
+			require.Equal(t, test.expectSuspended, suspended)
+			require.Equal(t, test.expectOk, ok)
+		})
+	}
+}
+
+func TestCreationStatement_resolveAuthProviders(t *testing.T) {
+	cs := creationStatement{
+		AuthProviders: []authProvider{
+			{Provider: "oidc-okta", ID: "{{display_name}}"},
+			{Provider: "oidc-azure", ID: "vault:{{username}}"},
+		},
+	}
+
+	expected := []authProvider{
+		{Provider: "oidc-okta", ID: "oidc-alice"},
+		{Provider: "oidc-azure", ID: "vault:v-alice-reader"},
+	}
+	require.Equal(t, expected, cs.resolveAuthProviders("v-alice-reader", "oidc-alice"))
+}
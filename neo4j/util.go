@@ -1,13 +1,33 @@
 package neo4j
 
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
 type no4jCommand interface {
 	command() (string, map[string]any)
 }
 
 type createUserCommand struct {
-	Username string        `bson:"createUser"`
-	Password string        `bson:"pwd,omitempty"`
-	Roles    []interface{} `bson:"roles"`
+	Username     string
+	Password     string
+	Roles        []string
+	HomeDatabase string
+	CopyRoleFrom string
+	Privileges   []graphPrivilege
+
+	// AuthProviders binds the user to external auth providers, in addition to
+	// the native password unless DisableNativeAuth is set.
+	AuthProviders     []authProvider
+	DisableNativeAuth bool
+}
+
+type grantRoleCommand struct {
+	Username string
+	Role     string
 }
 
 type updateUserCommand struct {
@@ -15,10 +35,77 @@ type updateUserCommand struct {
 	Password string `bson:"pwd"`
 }
 
+type createRoleCommand struct {
+	Name   string
+	CopyOf string
+}
+
+type dropRoleCommand struct {
+	Name     string
+	IfExists bool
+}
+
+type grantPrivilegeCommand struct {
+	Role      string
+	Privilege graphPrivilege
+}
+
+type showRoleCommand struct {
+	Name string
+}
+
+type showDatabaseCommand struct {
+	Name string
+}
+
+type showUserCommand struct {
+	Username string
+}
+
+type showServersCommand struct {
+	Legacy bool
+}
+
+type showCurrentUserCommand struct{}
+
+type showWriterCommand struct {
+	Database string
+}
+
+type showUsersCommand struct {
+	Community bool
+}
+
+type alterUserStatusCommand struct {
+	Username  string
+	Suspended bool
+	IfExists  bool
+}
+
+type recordExpirationCommand struct {
+	Username   string
+	Expiration time.Time
+}
+
+type deleteExpirationCommand struct {
+	Username string
+}
+
+type showExpiredUsersCommand struct {
+	Now time.Time
+}
+
+type markExpirationSweptCommand struct {
+	Username string
+}
+
 type dropUserCommand struct {
-	Username string `bson:"dropUser"`
+	Username string
+	IfExists bool
 }
 
+// neo4jRole, neo4jRoles and neo4jStatement make up the deprecated role
+// document format of creation statements, see parseLegacyCreationStatement.
 type neo4jRole struct {
 	Role string `json:"role" bson:"role"`
 	DB   string `json:"db"   bson:"db"`
@@ -33,33 +120,265 @@ type neo4jStatement struct {
 
 // Convert array of role documents like:
 //
-// [ { "role": "readWrite" }, { "role": "readWrite", "db": "test" } ]
+// [ { "role": "reader" }, { "role": "editor", "db": "test" } ]
 //
-// into a "standard" neo4j roles array containing both strings and role documents:
+// into the list of role names to grant:
 //
-// [ "readWrite", { "role": "readWrite", "db": "test" } ]
+// [ "reader", "editor" ]
 //
-// neo4j's createUser command accepts the latter.
-func (roles neo4jRoles) toStandardRolesArray() []interface{} {
-	var standardRolesArray []interface{}
+// Roles in neo4j are not scoped to a database, so the db of a role document
+// is not part of the grant.
+func (roles neo4jRoles) toRoleNames() []string {
+	var roleNames []string
 	for _, role := range roles {
-		if role.DB == "" {
-			standardRolesArray = append(standardRolesArray, role.Role)
-		} else {
-			standardRolesArray = append(standardRolesArray, role)
-		}
+		roleNames = append(roleNames, role.Role)
 	}
-	return standardRolesArray
+	return roleNames
+}
+
+// ephemeralRolePrefix is the prefix of the roles dedicated to a single user.
+const ephemeralRolePrefix = "vault_"
+
+// roleNameInvalidChars matches the characters neo4j doesn't accept in role
+// names, which may only consist of ASCII letters, digits and underscores.
+var roleNameInvalidChars = regexp.MustCompile(`[^A-Za-z0-9_]`)
+
+// ephemeralRoleName returns the name of the role dedicated to a single user.
+// Usernames generated by the default template contain dashes, so the invalid
+// characters are replaced, and the prefix makes the name start with a letter.
+func ephemeralRoleName(username string) string {
+	return ephemeralRolePrefix + roleNameInvalidChars.ReplaceAllString(username, "_")
+}
+
+// quoteIdentifier escapes a name so it can be used as an identifier in places
+// where neo4j does not accept parameters.
+func quoteIdentifier(name string) string {
+	return "`" + strings.ReplaceAll(name, "`", "``") + "`"
 }
 
 func (c createUserCommand) transform() (string, map[string]any) {
-	return "CREATE OR REPLACE USER $username SET PASSWORD $password CHANGE NOT REQUIRED", map[string]any{"username": c.Username, "password": c.Password}
+	params := map[string]any{"username": c.Username}
+
+	command := "CREATE USER $username"
+	switch {
+	case len(c.AuthProviders) == 0:
+		command += " SET PASSWORD $password CHANGE NOT REQUIRED"
+		params["password"] = c.Password
+	case !c.DisableNativeAuth:
+		command += " SET AUTH 'native' {SET PASSWORD $password SET PASSWORD CHANGE NOT REQUIRED}"
+		params["password"] = c.Password
+	}
+	for i, provider := range c.AuthProviders {
+		command += fmt.Sprintf(" SET AUTH $provider%d {SET ID $id%d}", i, i)
+		params[fmt.Sprintf("provider%d", i)] = provider.Provider
+		params[fmt.Sprintf("id%d", i)] = provider.ID
+	}
+
+	if c.HomeDatabase != "" {
+		command += " SET HOME DATABASE " + quoteIdentifier(c.HomeDatabase)
+	}
+	return command, params
+}
+
+// checkSupport returns an error naming the first field of the creation
+// statement the server doesn't support.
+func (c createUserCommand) checkSupport(server serverInfo) error {
+	if len(c.Roles) > 0 {
+		if err := server.requireEnterprise("roles"); err != nil {
+			return err
+		}
+	}
+	if c.HomeDatabase != "" {
+		if err := server.requireEnterprise("home_database"); err != nil {
+			return err
+		}
+		if err := server.requireVersion("home_database", 4, 3); err != nil {
+			return err
+		}
+	}
+	if c.CopyRoleFrom != "" {
+		if err := server.requireEnterprise("copy_role_from"); err != nil {
+			return err
+		}
+	}
+	if len(c.Privileges) > 0 {
+		if err := server.requireEnterprise("privileges"); err != nil {
+			return err
+		}
+	}
+	if len(c.AuthProviders) > 0 {
+		if err := server.requireEnterprise("auth_providers"); err != nil {
+			return err
+		}
+		if err := server.requireVersion("auth_providers", 5, 24); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c grantRoleCommand) transform() (string, map[string]any) {
+	return "GRANT ROLE $role TO $username", map[string]any{"username": c.Username, "role": c.Role}
 }
 
 func (c dropUserCommand) transform() (string, map[string]any) {
-	return "DROP USER $username", map[string]any{"username": c.Username}
+	command := "DROP USER $username"
+	if c.IfExists {
+		command += " IF EXISTS"
+	}
+	return command, map[string]any{"username": c.Username}
 }
 
 func (c updateUserCommand) transform() (string, map[string]any) {
 	return "ALTER USER $username SET  PASSWORD $password CHANGE NOT REQUIRED", map[string]any{"username": c.Username, "password": c.Password}
 }
+
+func (c createRoleCommand) transform() (string, map[string]any) {
+	if c.CopyOf != "" {
+		return "CREATE ROLE $role AS COPY OF $copyOf", map[string]any{"role": c.Name, "copyOf": c.CopyOf}
+	}
+	return "CREATE ROLE $role", map[string]any{"role": c.Name}
+}
+
+// checkSupport returns an error if the server has no roles, in which case no
+// role can exist to be dropped.
+func (c dropRoleCommand) checkSupport(server serverInfo) error {
+	return server.requireEnterprise("roles")
+}
+
+func (c dropRoleCommand) transform() (string, map[string]any) {
+	command := "DROP ROLE $role"
+	if c.IfExists {
+		command += " IF EXISTS"
+	}
+	return command, map[string]any{"role": c.Name}
+}
+
+// transform renders the privilege, e.g.:
+//
+//	GRANT MATCH {*} ON GRAPH `sales` NODES `Customer` TO `role`
+//	DENY READ {`ssn`} ON GRAPH * TO `role`
+//	GRANT ACCESS ON DATABASE `sales` TO `role`
+//
+// Privilege commands don't accept parameters, the names are quoted instead.
+func (c grantPrivilegeCommand) transform() (string, map[string]any) {
+	p := c.Privilege
+
+	var command strings.Builder
+	if p.Deny {
+		command.WriteString("DENY ")
+	} else {
+		command.WriteString("GRANT ")
+	}
+	command.WriteString(strings.ToUpper(p.Action))
+
+	if strings.ToLower(p.Action) == privilegeActionAccess {
+		command.WriteString(" ON DATABASE ")
+		command.WriteString(quoteNames([]string{p.Graph}))
+	} else {
+		if len(p.Properties) > 0 {
+			command.WriteString(" {" + quoteNames(p.Properties) + "}")
+		}
+		command.WriteString(" ON GRAPH ")
+		command.WriteString(quoteNames([]string{p.Graph}))
+		if len(p.Nodes) > 0 {
+			command.WriteString(" NODES " + quoteNames(p.Nodes))
+		}
+		if len(p.Relationships) > 0 {
+			command.WriteString(" RELATIONSHIPS " + quoteNames(p.Relationships))
+		}
+	}
+
+	command.WriteString(" TO ")
+	command.WriteString(quoteIdentifier(c.Role))
+	return command.String(), nil
+}
+
+// quoteNames quotes and joins names, leaving the * wildcard as is.
+func quoteNames(names []string) string {
+	quoted := make([]string, len(names))
+	for i, name := range names {
+		if name == "*" {
+			quoted[i] = name
+		} else {
+			quoted[i] = quoteIdentifier(name)
+		}
+	}
+	return strings.Join(quoted, ", ")
+}
+
+func (c showRoleCommand) transform() (string, map[string]any) {
+	return "SHOW ROLES YIELD role WHERE role = $role RETURN role", map[string]any{"role": c.Name}
+}
+
+func (c showDatabaseCommand) transform() (string, map[string]any) {
+	return "SHOW DATABASES YIELD name WHERE name = $name RETURN name", map[string]any{"name": c.Name}
+}
+
+func (c showUserCommand) transform() (string, map[string]any) {
+	return "SHOW USERS YIELD user WHERE user = $username RETURN user", map[string]any{"username": c.Username}
+}
+
+func (c showServersCommand) transform() (string, map[string]any) {
+	// Neo4j 4.x lists the members of a cluster with bolt:// addresses.
+	if c.Legacy {
+		return "CALL dbms.cluster.overview() YIELD addresses RETURN [a IN addresses WHERE a STARTS WITH 'bolt://' | substring(a, 7)][0] AS address", nil
+	}
+	return "SHOW SERVERS YIELD address, health WHERE health = 'Available' RETURN address", nil
+}
+
+func (c showCurrentUserCommand) transform() (string, map[string]any) {
+	return "SHOW CURRENT USER YIELD user RETURN user", nil
+}
+
+func (c showWriterCommand) transform() (string, map[string]any) {
+	return "CALL dbms.routing.getRoutingTable({}, $database) YIELD servers UNWIND servers AS server WITH server WHERE server.role = 'WRITE' RETURN server.addresses[0] AS address", map[string]any{
+		"database": c.Database,
+	}
+}
+
+func (c showUsersCommand) transform() (string, map[string]any) {
+	// Users of the Community Edition have no status.
+	if c.Community {
+		return "SHOW USERS YIELD user RETURN user, false AS suspended", nil
+	}
+	return "SHOW USERS YIELD user, suspended RETURN user, suspended", nil
+}
+
+// checkSupport returns an error if the server has no user status.
+func (c alterUserStatusCommand) checkSupport(server serverInfo) error {
+	return server.requireEnterprise("user status")
+}
+
+func (c alterUserStatusCommand) transform() (string, map[string]any) {
+	command := "ALTER USER $username"
+	if c.IfExists {
+		command += " IF EXISTS"
+	}
+	if c.Suspended {
+		command += " SET STATUS SUSPENDED"
+	} else {
+		command += " SET STATUS ACTIVE"
+	}
+	return command, map[string]any{"username": c.Username}
+}
+
+// The expiration of users is recorded as VaultUser nodes in the metadata
+// database. Times are bound in UTC, as the driver sends the zone name of
+// their location, and neo4j doesn't know the "Local" zone of local times.
+
+func (c recordExpirationCommand) transform() (string, map[string]any) {
+	return "MERGE (u:VaultUser {username: $username}) ON CREATE SET u.created_at = datetime() SET u.expires_at = $expiration REMOVE u.suspended_at", map[string]any{"username": c.Username, "expiration": c.Expiration.UTC()}
+}
+
+func (c deleteExpirationCommand) transform() (string, map[string]any) {
+	return "MATCH (u:VaultUser {username: $username}) DELETE u", map[string]any{"username": c.Username}
+}
+
+func (c showExpiredUsersCommand) transform() (string, map[string]any) {
+	return "MATCH (u:VaultUser) WHERE u.expires_at < $now AND u.suspended_at IS NULL RETURN u.username", map[string]any{"now": c.Now.UTC()}
+}
+
+func (c markExpirationSweptCommand) transform() (string, map[string]any) {
+	return "MATCH (u:VaultUser {username: $username}) SET u.suspended_at = datetime()", map[string]any{"username": c.Username}
+}
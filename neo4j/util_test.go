@@ -0,0 +1,212 @@
+package neo4j
+
+import (
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/database/dbplugin/v5"
+	"github.com/hashicorp/vault/sdk/helper/template"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCreateUserCommand_transform(t *testing.T) {
+	cmd := createUserCommand{
+		Username:     "user",
+		Password:     "secret",
+		HomeDatabase: "sales",
+	}
+
+	command, params := cmd.transform()
+	require.Equal(t, "CREATE USER $username SET PASSWORD $password CHANGE NOT REQUIRED SET HOME DATABASE `sales`", command)
+	require.Equal(t, map[string]any{"username": "user", "password": "secret"}, params)
+
+	cmd.AuthProviders = []authProvider{{Provider: "oidc-okta", ID: "alice"}}
+	command, params = cmd.transform()
+	require.Equal(t, "CREATE USER $username SET AUTH 'native' {SET PASSWORD $password SET PASSWORD CHANGE NOT REQUIRED} SET AUTH $provider0 {SET ID $id0} SET HOME DATABASE `sales`", command)
+	require.Equal(t, map[string]any{"username": "user", "password": "secret", "provider0": "oidc-okta", "id0": "alice"}, params)
+
+	cmd.DisableNativeAuth = true
+	command, params = cmd.transform()
+	require.Equal(t, "CREATE USER $username SET AUTH $provider0 {SET ID $id0} SET HOME DATABASE `sales`", command)
+	require.Equal(t, map[string]any{"username": "user", "provider0": "oidc-okta", "id0": "alice"}, params)
+}
+
+func TestCreateRoleCommand_transform(t *testing.T) {
+	cmd := createRoleCommand{
+		Name: ephemeralRoleName("v-token-copy-role-1715236001"),
+	}
+	command, params := cmd.transform()
+	require.Equal(t, "CREATE ROLE $role", command)
+	require.Equal(t, map[string]any{"role": "vault_v_token_copy_role_1715236001"}, params)
+
+	cmd.CopyOf = "analyst_template"
+	command, params = cmd.transform()
+	require.Equal(t, "CREATE ROLE $role AS COPY OF $copyOf", command)
+	require.Equal(t, map[string]any{"role": "vault_v_token_copy_role_1715236001", "copyOf": "analyst_template"}, params)
+}
+
+func TestGrantPrivilegeCommand_transform(t *testing.T) {
+	tests := map[string]struct {
+		privilege graphPrivilege
+		expected  string
+	}{
+		"match on nodes": {
+			privilege: graphPrivilege{Action: "match", Properties: []string{"*"}, Graph: "sales", Nodes: []string{"Customer"}},
+			expected:  "GRANT MATCH {*} ON GRAPH `sales` NODES `Customer` TO `vault_user`",
+		},
+		"write": {
+			privilege: graphPrivilege{Action: "write", Graph: "sales"},
+			expected:  "GRANT WRITE ON GRAPH `sales` TO `vault_user`",
+		},
+		"deny read": {
+			privilege: graphPrivilege{Action: "read", Deny: true, Properties: []string{"ssn", "dob"}, Graph: "*"},
+			expected:  "DENY READ {`ssn`, `dob`} ON GRAPH * TO `vault_user`",
+		},
+		"traverse relationships": {
+			privilege: graphPrivilege{Action: "traverse", Graph: "sales", Relationships: []string{"BOUGHT", "*"}},
+			expected:  "GRANT TRAVERSE ON GRAPH `sales` RELATIONSHIPS `BOUGHT`, * TO `vault_user`",
+		},
+		"access": {
+			privilege: graphPrivilege{Action: "access", Graph: "sales"},
+			expected:  "GRANT ACCESS ON DATABASE `sales` TO `vault_user`",
+		},
+		"quoted names": {
+			privilege: graphPrivilege{Action: "create", Graph: "sales", Nodes: []string{"Bad`Label"}},
+			expected:  "GRANT CREATE ON GRAPH `sales` NODES `Bad``Label` TO `vault_user`",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			cmd := grantPrivilegeCommand{
+				Role:      ephemeralRoleName("user"),
+				Privilege: test.privilege,
+			}
+			command, params := cmd.transform()
+			require.Equal(t, test.expected, command)
+			require.Empty(t, params)
+		})
+	}
+}
+
+func TestEphemeralRoleName(t *testing.T) {
+	up, err := template.NewTemplate(template.Template(defaultUserNameTemplate))
+	require.NoError(t, err)
+	username, err := up.Generate(dbplugin.UsernameMetadata{DisplayName: "token", RoleName: "copy.role"})
+	require.NoError(t, err)
+
+	role := ephemeralRoleName(username)
+	require.Regexp(t, regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_]*$`), role)
+	require.Equal(t, role, ephemeralRoleName(username))
+
+	require.Equal(t, "vault_v_token_copy_role", ephemeralRoleName("v-token-copy-role"))
+}
+
+func TestAlterUserStatusCommand_transform(t *testing.T) {
+	cmd := alterUserStatusCommand{
+		Username:  "user",
+		Suspended: true,
+		IfExists:  true,
+	}
+	command, params := cmd.transform()
+	require.Equal(t, "ALTER USER $username IF EXISTS SET STATUS SUSPENDED", command)
+	require.Equal(t, map[string]any{"username": "user"}, params)
+
+	cmd = alterUserStatusCommand{
+		Username: "user",
+	}
+	command, _ = cmd.transform()
+	require.Equal(t, "ALTER USER $username SET STATUS ACTIVE", command)
+}
+
+func TestCreateUserCommand_checkSupport(t *testing.T) {
+	community, err := parseServerInfo("5.19.0", "community")
+	require.NoError(t, err)
+	enterprise4, err := parseServerInfo("4.2.0", "enterprise")
+	require.NoError(t, err)
+	enterprise5, err := parseServerInfo("5.19.0", "enterprise")
+	require.NoError(t, err)
+
+	tests := map[string]struct {
+		cmd    createUserCommand
+		server serverInfo
+
+		expectErr string
+	}{
+		"community without roles": {
+			cmd:    createUserCommand{Username: "user"},
+			server: community,
+		},
+		"community with roles": {
+			cmd:       createUserCommand{Username: "user", Roles: []string{"reader"}},
+			server:    community,
+			expectErr: "roles: not supported on Community Edition",
+		},
+		"community with home database": {
+			cmd:       createUserCommand{Username: "user", HomeDatabase: "sales"},
+			server:    community,
+			expectErr: "home_database: not supported on Community Edition",
+		},
+		"community with privileges": {
+			cmd:       createUserCommand{Username: "user", Privileges: []graphPrivilege{{Action: "write", Graph: "sales"}}},
+			server:    community,
+			expectErr: "privileges: not supported on Community Edition",
+		},
+		"home database on 4.2": {
+			cmd:       createUserCommand{Username: "user", HomeDatabase: "sales"},
+			server:    enterprise4,
+			expectErr: "home_database: requires neo4j 4.3 or later, the server runs 4.2.0",
+		},
+		"auth providers on 5.19": {
+			cmd:       createUserCommand{Username: "user", AuthProviders: []authProvider{{Provider: "oidc", ID: "alice"}}},
+			server:    enterprise5,
+			expectErr: "auth_providers: requires neo4j 5.24 or later, the server runs 5.19.0",
+		},
+		"unknown server": {
+			cmd: createUserCommand{Username: "user", Roles: []string{"reader"}, AuthProviders: []authProvider{{Provider: "oidc", ID: "alice"}}},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			err := test.cmd.checkSupport(test.server)
+			if test.expectErr != "" {
+				require.EqualError(t, err, test.expectErr)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestDropRoleCommand_checkSupport(t *testing.T) {
+	community, err := parseServerInfo("5.19.0", "community")
+	require.NoError(t, err)
+	enterprise, err := parseServerInfo("5.19.0", "enterprise")
+	require.NoError(t, err)
+
+	cmd := dropRoleCommand{Name: ephemeralRoleName("user"), IfExists: true}
+	require.EqualError(t, cmd.checkSupport(community), "roles: not supported on Community Edition")
+	require.NoError(t, cmd.checkSupport(enterprise))
+	require.NoError(t, cmd.checkSupport(serverInfo{}))
+}
+
+func TestExpirationCommands_transform(t *testing.T) {
+	now := time.Now()
+
+	recordExpirationCmd := recordExpirationCommand{
+		Username:   "user",
+		Expiration: now,
+	}
+	_, params := recordExpirationCmd.transform()
+	require.Equal(t, time.UTC, params["expiration"].(time.Time).Location())
+	require.True(t, now.Equal(params["expiration"].(time.Time)))
+
+	showExpiredUsersCmd := showExpiredUsersCommand{
+		Now: now,
+	}
+	_, params = showExpiredUsersCmd.transform()
+	require.Equal(t, time.UTC, params["now"].(time.Time).Location())
+	require.True(t, now.Equal(params["now"].(time.Time)))
+}